@@ -2,8 +2,8 @@ package archive
 
 import (
 	"archive/zip"
+	"errors"
 	"fmt"
-	"fyne.io/fyne/v2/dialog"
 	"io"
 	"os"
 	"path/filepath"
@@ -11,99 +11,224 @@ import (
 )
 
 /*
-Unzip allows to unzip an archive to a destination
+Unzip allows to unzip an archive to a destination. the archive is checked against the limits first, and
+the files are created with safe permissions whatever the modes stored in the archive.
 */
-func Unzip(src string, dest string) ([]string, error) {
-	var filenames []string
+func Unzip(src string, dest string) (filenames []string, err error) {
 	r, err := zip.OpenReader(src)
 	if err != nil {
 		return filenames, err
 	}
-	defer func(r *zip.ReadCloser) {
-		err := r.Close()
-		if err != nil {
-			dialog.ShowError(err, nil)
-		}
-	}(r)
+	defer closeWith(r, &err)
+	if err = checkEntries(src, r.File); err != nil {
+		return filenames, err
+	}
 	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
 		fpath := filepath.Join(dest, filepath.Base(f.Name))
 		if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
-			return filenames, fmt.Errorf("%s: illegal file path", fpath)
+			return filenames, &EntryError{Archive: src, Entry: f.Name, Err: ErrUnsafePath}
 		}
-		err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm)
-		if err != nil {
-			return nil, err
-		}
-		filenames = append(filenames, fpath)
-		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
+		if err = os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
 			return filenames, err
 		}
-		rc, err := f.Open()
-		if err != nil {
+		if err = extractEntry(f, fpath); err != nil {
 			return filenames, err
 		}
-		_, err = io.Copy(outFile, rc)
-		err = outFile.Close()
-		if err != nil {
-			return nil, err
-		}
-		err = rc.Close()
-		if err != nil {
-			return nil, err
+		filenames = append(filenames, fpath)
+	}
+	return filenames, nil
+}
+
+func extractEntry(f *zip.File, fpath string) (err error) {
+	outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer closeWith(outFile, &err)
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer closeWith(rc, &err)
+	// never write more than the size declared by the entry
+	_, err = io.Copy(outFile, io.LimitReader(rc, int64(f.UncompressedSize64)))
+	return err
+}
+
+/*
+Create writes a new archive with the given files, in this order and stored under their base name, and
+the metadata when info is not nil. the archive is written next to its final name first, so an existing
+archive is only replaced by a complete one.
+*/
+func Create(filename string, files []string, info *ComicInfo, options CreateOptions) error {
+	tmpName, err := writeTemp(filepath.Dir(filename), func(zipWriter *zip.Writer) error {
+		if options.Workers > 1 {
+			if err := addFilesInParallel(zipWriter, files, options); err != nil {
+				return err
+			}
+		} else {
+			for _, file := range files {
+				if err := addFileToZip(zipWriter, file, options.method(file)); err != nil {
+					return err
+				}
+			}
 		}
-		if err != nil {
-			return filenames, err
+		if info != nil {
+			return WriteComicInfo(zipWriter, *info)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	return filenames, nil
+	return os.Rename(tmpName, filename)
 }
 
-// ZipFiles compresses one or many files into a single zip archive file.
-// Param 1: filename is the output zip file's name.
-// Param 2: files is a list of files to add to the zip.
-func ZipFiles(filename string, files []string) error {
+// Append adds files to an existing archive, the files replace the entries with the same name
+func Append(filename string, files []string) error {
+	names := map[string]bool{}
+	for _, file := range files {
+		names[filepath.Base(file)] = true
+	}
+	return Rewrite(filename, func(name string) bool {
+		return !names[name]
+	}, func(zipWriter *zip.Writer) error {
+		for _, file := range files {
+			if err := addFileToZip(zipWriter, file, CreateOptions{}.method(file)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
 
-	newZipFile, err := os.Create(filename)
+/*
+Rewrite writes an archive again: the entries accepted by keep are copied without being compressed
+again, then extra can add new entries. the archive is replaced only once the new one is complete.
+*/
+func Rewrite(filename string, keep func(name string) bool, extra func(zipWriter *zip.Writer) error) error {
+	r, err := zip.OpenReader(filename)
 	if err != nil {
 		return err
 	}
-	defer func(newZipFile *os.File) {
-		err := newZipFile.Close()
-		if err != nil {
-			dialog.ShowError(err, nil)
+	tmpName, err := writeTemp(filepath.Dir(filename), func(zipWriter *zip.Writer) error {
+		for _, f := range r.File {
+			if keep != nil && !keep(f.Name) {
+				continue
+			}
+			if err := zipWriter.Copy(f); err != nil {
+				return err
+			}
+		}
+		if extra != nil {
+			return extra(zipWriter)
+		}
+		return nil
+	})
+	// the archive must be closed before being replaced
+	closeWith(r, &err)
+	if err != nil {
+		if tmpName != "" {
+			_ = os.Remove(tmpName)
 		}
-	}(newZipFile)
+		return err
+	}
+	return os.Rename(tmpName, filename)
+}
 
-	zipWriter := zip.NewWriter(newZipFile)
-	defer func(zipWriter *zip.Writer) {
-		err := zipWriter.Close()
-		if err != nil {
-			dialog.ShowError(err, nil)
+// List returns the names of the pages of an archive, in natural order
+func List(filename string) ([]string, error) {
+	r, err := Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return r.Pages(), nil
+}
+
+// ReadEntry returns the content of an entry of an archive
+func ReadEntry(filename, name string) (data []byte, err error) {
+	r, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer closeWith(r, &err)
+	if err = checkEntries(filename, r.File); err != nil {
+		return nil, err
+	}
+	for _, f := range r.File {
+		if f.Name == name {
+			return readEntry(f)
 		}
-	}(zipWriter)
+	}
+	return nil, errors.New(fmt.Sprintf("%s has no entry %s", filename, name))
+}
 
-	// Add files to zip
-	for _, file := range files {
-		if err = addFileToZip(zipWriter, file); err != nil {
-			return err
+/*
+Verify reads all the entries of an archive, so a truncated archive or an entry with a wrong checksum
+is reported. it returns the number of pages of the archive.
+*/
+func Verify(filename string) (nbPages int, err error) {
+	r, err := zip.OpenReader(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer closeWith(r, &err)
+	if err = checkEntries(filename, r.File); err != nil {
+		return 0, err
+	}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if _, err = readEntry(f); err != nil {
+			return nbPages, errors.New(fmt.Sprintf("entry %s of %s is corrupted: %s", f.Name, filename, err))
+		}
+		if IsPage(f.Name) {
+			nbPages++
 		}
 	}
-	return nil
+	return nbPages, nil
 }
 
-func addFileToZip(zipWriter *zip.Writer, filename string) error {
+/*
+writeTemp writes an archive in a temporary file of dir, and returns its name. the temporary file is
+removed if write fails, so the caller only has to rename it.
+*/
+func writeTemp(dir string, write func(zipWriter *zip.Writer) error) (tmpName string, err error) {
+	tmpFile, err := os.CreateTemp(dir, ".archive-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmpFile.Close()
+			_ = os.Remove(tmpFile.Name())
+			tmpName = ""
+		}
+	}()
+	zipWriter := zip.NewWriter(tmpFile)
+	if err = write(zipWriter); err != nil {
+		return "", err
+	}
+	if err = zipWriter.Close(); err != nil {
+		return "", err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return "", err
+	}
+	return tmpFile.Name(), nil
+}
 
+func addFileToZip(zipWriter *zip.Writer, filename string, method uint16) (err error) {
 	fileToZip, err := os.Open(filename)
 	if err != nil {
 		return err
 	}
-	defer func(fileToZip *os.File) {
-		err := fileToZip.Close()
-		if err != nil {
-			dialog.ShowError(err, nil)
-		}
-	}(fileToZip)
+	defer closeWith(fileToZip, &err)
 
 	// Get the file information
 	info, err := fileToZip.Stat()
@@ -111,18 +236,15 @@ func addFileToZip(zipWriter *zip.Writer, filename string) error {
 		return err
 	}
 
+	// FileInfoHeader only uses the base name of the file, the archives are flat,
+	// and it keeps the modification time of the file
 	header, err := zip.FileInfoHeader(info)
 	if err != nil {
 		return err
 	}
 
-	// Using FileInfoHeader() above only uses the basename of the file. If we want
-	// to preserve the folder structure we can overwrite this with the full path.
-	header.Name = filename
-
-	// Change to deflate to gain better compression
-	// see http://golang.org/pkg/archive/zip/#pkg-constants
-	header.Method = zip.Deflate
+	// store or deflate, see http://golang.org/pkg/archive/zip/#pkg-constants
+	header.Method = method
 
 	writer, err := zipWriter.CreateHeader(header)
 	if err != nil {
@@ -131,3 +253,20 @@ func addFileToZip(zipWriter *zip.Writer, filename string) error {
 	_, err = io.Copy(writer, fileToZip)
 	return err
 }
+
+// readEntry reads an entry in memory, never more than the size it declares
+func readEntry(f *zip.File) (data []byte, err error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer closeWith(rc, &err)
+	return io.ReadAll(io.LimitReader(rc, int64(f.UncompressedSize64)))
+}
+
+// closeWith closes c, and reports the close error if nothing else failed before
+func closeWith(c io.Closer, err *error) {
+	if e := c.Close(); e != nil && *err == nil {
+		*err = e
+	}
+}
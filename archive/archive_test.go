@@ -0,0 +1,241 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writePage writes a small png page in dir, and returns its path
+func writePage(t testing.TB, dir, name string, width, height int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, x%height, color.RGBA{R: uint8(x), A: 255})
+	}
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, name)
+	if err := os.WriteFile(filename, buffer.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// writePages writes nbPages pages named p1.png to pN.png in dir
+func writePages(t testing.TB, dir string, nbPages int) []string {
+	t.Helper()
+	var files []string
+	for i := 1; i <= nbPages; i++ {
+		files = append(files, writePage(t, dir, fmt.Sprintf("p%d.png", i), 16, 24))
+	}
+	return files
+}
+
+func TestCreateRoundTrip(t *testing.T) {
+	for _, options := range []CreateOptions{
+		{},
+		{Compression: CompressionStore},
+		{Compression: CompressionDeflate},
+		{Compression: CompressionDeflate, Workers: 4},
+	} {
+		t.Run(fmt.Sprintf("compression %d workers %d", options.Compression, options.Workers), func(t *testing.T) {
+			dir := t.TempDir()
+			files := writePages(t, dir, 12)
+			filename := filepath.Join(dir, "chapter.cbz")
+			info := &ComicInfo{Series: "Series", Number: "3", Manga: "YesAndRightToLeft"}
+			if err := Create(filename, files, info, options); err != nil {
+				t.Fatal(err)
+			}
+
+			nbPages, err := Verify(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if nbPages != len(files) {
+				t.Errorf("Verify found %d pages, expected %d", nbPages, len(files))
+			}
+
+			pages, err := List(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var expected []string
+			for _, file := range files {
+				expected = append(expected, filepath.Base(file))
+			}
+			if !reflect.DeepEqual(pages, expected) {
+				t.Errorf("List returned %v, expected %v", pages, expected)
+			}
+
+			found, err := HasComicInfo(filename)
+			if err != nil || !found {
+				t.Errorf("the metadata is missing: %v", err)
+			}
+			meta, err := ReadMetadata(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta.Series != "Series" {
+				t.Errorf("the series is %q, expected %q", meta.Series, "Series")
+			}
+		})
+	}
+}
+
+func TestOpenReadPage(t *testing.T) {
+	dir := t.TempDir()
+	files := writePages(t, dir, 3)
+	filename := filepath.Join(dir, "chapter.cbz")
+	if err := Create(filename, files, nil, CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if len(r.Pages()) != len(files) {
+		t.Fatalf("the archive has %d pages, expected %d", len(r.Pages()), len(files))
+	}
+	for i, file := range files {
+		data, err := r.ReadPage(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := os.ReadFile(file)
+		if !bytes.Equal(data, expected) {
+			t.Errorf("page %d differs from %s", i, file)
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || format != "png" || cfg.Width != 16 || cfg.Height != 24 {
+			t.Errorf("page %d is not the png written: %s %dx%d %v", i, format, cfg.Width, cfg.Height, err)
+		}
+	}
+	if _, err := r.ReadPage(len(files)); err == nil {
+		t.Error("a page after the last one can be read")
+	}
+	if _, err := r.ReadPage(-1); err == nil {
+		t.Error("a page before the first one can be read")
+	}
+}
+
+func TestAppend(t *testing.T) {
+	dir := t.TempDir()
+	files := writePages(t, dir, 2)
+	filename := filepath.Join(dir, "chapter.cbz")
+	if err := Create(filename, files, nil, CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// p2.png is replaced by a bigger page, p3.png is added
+	other := t.TempDir()
+	replaced := writePage(t, other, "p2.png", 32, 48)
+	added := writePage(t, other, "p3.png", 16, 24)
+	if err := Append(filename, []string{replaced, added}); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := List(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"p1.png", "p2.png", "p3.png"}; !reflect.DeepEqual(pages, expected) {
+		t.Errorf("List returned %v, expected %v", pages, expected)
+	}
+	data, err := ReadEntry(filename, "p2.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := os.ReadFile(replaced)
+	if !bytes.Equal(data, expected) {
+		t.Error("p2.png was not replaced")
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	dir := t.TempDir()
+	files := writePages(t, dir, 3)
+	filename := filepath.Join(dir, "chapter.cbz")
+	if err := Create(filename, files, nil, CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Rewrite(filename, func(name string) bool {
+		return name != "p2.png"
+	}, func(zipWriter *zip.Writer) error {
+		return WriteComicInfo(zipWriter, ComicInfo{Title: "Rewritten"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := List(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"p1.png", "p3.png"}; !reflect.DeepEqual(pages, expected) {
+		t.Errorf("List returned %v, expected %v", pages, expected)
+	}
+	meta, err := ReadMetadata(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "Rewritten" {
+		t.Errorf("the title is %q, expected %q", meta.Title, "Rewritten")
+	}
+	// the temporary archive is renamed, nothing else is left in the folder
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != len(files)+1 {
+		t.Errorf("the folder has %d files, expected %d", len(entries), len(files)+1)
+	}
+}
+
+func TestReadEntry(t *testing.T) {
+	dir := t.TempDir()
+	files := writePages(t, dir, 1)
+	filename := filepath.Join(dir, "chapter.cbz")
+	if err := Create(filename, files, &ComicInfo{Series: "Series"}, CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ReadEntry(filename, ComicInfoFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("<Series>Series</Series>")) {
+		t.Errorf("unexpected metadata %s", data)
+	}
+	if _, err := ReadEntry(filename, "missing.png"); err == nil {
+		t.Error("a missing entry can be read")
+	}
+}
+
+func TestVerifyCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	files := writePages(t, dir, 2)
+	filename := filepath.Join(dir, "chapter.cbz")
+	if err := Create(filename, files, nil, CreateOptions{Compression: CompressionDeflate}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filename, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(filename); err == nil {
+		t.Error("a truncated archive is verified")
+	}
+}
@@ -0,0 +1,104 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+// ComicInfoFileName is the name of the metadata entry read by most comic readers
+const ComicInfoFileName = "ComicInfo.xml"
+
+/*
+ComicInfo is the metadata stored in a cbz archive, following the ComicInfo 2.0 schema used by
+ComicRack, Komga, Kavita and most of the comic readers.
+*/
+type ComicInfo struct {
+	XMLName     xml.Name        `xml:"ComicInfo"`
+	XmlnsXsi    string          `xml:"xmlns:xsi,attr,omitempty"`
+	XmlnsXsd    string          `xml:"xmlns:xsd,attr,omitempty"`
+	Title       string          `xml:"Title,omitempty"`
+	Series      string          `xml:"Series,omitempty"`
+	Number      string          `xml:"Number,omitempty"`
+	Volume      int             `xml:"Volume,omitempty"`
+	Summary     string          `xml:"Summary,omitempty"`
+	Notes       string          `xml:"Notes,omitempty"`
+	Year        int             `xml:"Year,omitempty"`
+	Writer      string          `xml:"Writer,omitempty"`
+	Penciller   string          `xml:"Penciller,omitempty"`
+	Genre       string          `xml:"Genre,omitempty"`
+	Tags        string          `xml:"Tags,omitempty"`
+	Web         string          `xml:"Web,omitempty"`
+	PageCount   int             `xml:"PageCount,omitempty"`
+	LanguageISO string          `xml:"LanguageISO,omitempty"`
+	Manga       string          `xml:"Manga,omitempty"`
+	AgeRating   string          `xml:"AgeRating,omitempty"`
+	Pages       []ComicPageInfo `xml:"Pages>Page,omitempty"`
+}
+
+// ComicPageInfo describes a page of the archive, Image is the index of the page starting at 0
+type ComicPageInfo struct {
+	Image       int    `xml:"Image,attr"`
+	Type        string `xml:"Type,attr,omitempty"`
+	DoublePage  bool   `xml:"DoublePage,attr,omitempty"`
+	ImageSize   int64  `xml:"ImageSize,attr,omitempty"`
+	ImageWidth  int    `xml:"ImageWidth,attr,omitempty"`
+	ImageHeight int    `xml:"ImageHeight,attr,omitempty"`
+}
+
+// Marshal returns the xml document of the metadata, with its header
+func (info ComicInfo) Marshal() ([]byte, error) {
+	info.XmlnsXsi = "http://www.w3.org/2001/XMLSchema-instance"
+	info.XmlnsXsd = "http://www.w3.org/2001/XMLSchema"
+	data, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// WriteComicInfo adds the metadata entry to an archive being written
+func WriteComicInfo(zipWriter *zip.Writer, info ComicInfo) error {
+	data, err := info.Marshal()
+	if err != nil {
+		return err
+	}
+	writer, err := zipWriter.CreateHeader(&zip.FileHeader{
+		Name:     ComicInfoFileName,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+// HasComicInfo tells if an archive already contains a metadata entry
+func HasComicInfo(filename string) (found bool, err error) {
+	r, err := zip.OpenReader(filename)
+	if err != nil {
+		return false, err
+	}
+	defer closeWith(r, &err)
+	for _, f := range r.File {
+		if strings.EqualFold(f.Name, ComicInfoFileName) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+/*
+AddComicInfo rewrites an existing archive with the given metadata, replacing the previous metadata
+entry if any. the pages are copied without being compressed again.
+*/
+func AddComicInfo(filename string, info ComicInfo) error {
+	return Rewrite(filename, func(name string) bool {
+		return !strings.EqualFold(name, ComicInfoFileName)
+	}, func(zipWriter *zip.Writer) error {
+		return WriteComicInfo(zipWriter, info)
+	})
+}
@@ -0,0 +1,157 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Compression tells how the entries of a new archive are compressed
+type Compression int
+
+const (
+	// CompressionAuto stores the images, that are already compressed, and deflates the other entries
+	CompressionAuto Compression = iota
+	// CompressionStore stores all the entries without compression
+	CompressionStore
+	// CompressionDeflate deflates all the entries
+	CompressionDeflate
+)
+
+// ParseCompression reads a compression mode from the configuration, auto is the default
+func ParseCompression(value string) Compression {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "store":
+		return CompressionStore
+	case "deflate":
+		return CompressionDeflate
+	}
+	return CompressionAuto
+}
+
+/*
+CreateOptions are the options used to write the entries of a new archive. when Workers is more than 1,
+the entries are compressed in parallel, then written in order.
+*/
+type CreateOptions struct {
+	Compression Compression
+	Workers     int
+}
+
+// storedExtensions are the formats already compressed, deflating them again saves almost nothing
+var storedExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+	".avif": true,
+}
+
+// method returns the zip method used for an entry
+func (options CreateOptions) method(name string) uint16 {
+	switch options.Compression {
+	case CompressionStore:
+		return zip.Store
+	case CompressionDeflate:
+		return zip.Deflate
+	}
+	if storedExtensions[strings.ToLower(path.Ext(name))] {
+		return zip.Store
+	}
+	return zip.Deflate
+}
+
+type preparedEntry struct {
+	header *zip.FileHeader
+	data   []byte
+	err    error
+}
+
+// prepareEntry reads and compresses a file in memory, so it can be written later with CreateRaw
+func prepareEntry(filename string, method uint16) (entry preparedEntry) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		entry.err = err
+		return
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		entry.err = err
+		return
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		entry.err = err
+		return
+	}
+	header.Method = method
+	header.CRC32 = crc32.ChecksumIEEE(data)
+	header.UncompressedSize64 = uint64(len(data))
+	if method == zip.Deflate {
+		var buffer bytes.Buffer
+		writer, err := flate.NewWriter(&buffer, flate.DefaultCompression)
+		if err != nil {
+			entry.err = err
+			return
+		}
+		if _, err = writer.Write(data); err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			entry.err = err
+			return
+		}
+		data = buffer.Bytes()
+	}
+	header.CompressedSize64 = uint64(len(data))
+	entry.header = header
+	entry.data = data
+	return
+}
+
+/*
+addFilesInParallel compresses the files with several workers, and writes them in their order. at most
+workers entries are kept in memory at the same time.
+*/
+func addFilesInParallel(zipWriter *zip.Writer, files []string, options CreateOptions) error {
+	results := make([]chan preparedEntry, len(files))
+	for i := range results {
+		results[i] = make(chan preparedEntry, 1)
+	}
+	slots := make(chan struct{}, options.Workers)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i, file := range files {
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+			go func(i int, file string) {
+				results[i] <- prepareEntry(file, options.method(file))
+			}(i, file)
+		}
+	}()
+	for i := range files {
+		entry := <-results[i]
+		<-slots
+		if entry.err != nil {
+			return entry.err
+		}
+		writer, err := zipWriter.CreateRaw(entry.header)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(writer, bytes.NewReader(entry.data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package archive
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchmarkPages is the number of pages of the synthetic chapter, each of benchmarkPageSize bytes
+const benchmarkPages = 40
+const benchmarkPageSize = 512 << 10
+
+/*
+writeChapter writes a large synthetic chapter: the pages are random bytes, as hard to compress as real
+images, followed by a blank area like the margins of a scan.
+*/
+func writeChapter(b *testing.B) []string {
+	b.Helper()
+	dir := b.TempDir()
+	random := rand.New(rand.NewSource(1))
+	var files []string
+	for i := 1; i <= benchmarkPages; i++ {
+		data := make([]byte, benchmarkPageSize)
+		random.Read(data[:benchmarkPageSize*3/4])
+		filename := filepath.Join(dir, fmt.Sprintf("%03d.jpg", i))
+		if err := os.WriteFile(filename, data, 0644); err != nil {
+			b.Fatal(err)
+		}
+		files = append(files, filename)
+	}
+	return files
+}
+
+func benchmarkCreate(b *testing.B, options CreateOptions) {
+	files := writeChapter(b)
+	filename := filepath.Join(b.TempDir(), "chapter.cbz")
+	b.SetBytes(benchmarkPages * benchmarkPageSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Create(filename, files, nil, options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCreateStore(b *testing.B) {
+	benchmarkCreate(b, CreateOptions{Compression: CompressionStore})
+}
+
+func BenchmarkCreateDeflate(b *testing.B) {
+	benchmarkCreate(b, CreateOptions{Compression: CompressionDeflate})
+}
+
+func BenchmarkCreateSerial(b *testing.B) {
+	benchmarkCreate(b, CreateOptions{Compression: CompressionDeflate, Workers: 1})
+}
+
+func BenchmarkCreateParallel(b *testing.B) {
+	benchmarkCreate(b, CreateOptions{Compression: CompressionDeflate, Workers: 4})
+}
@@ -1,9 +1,3 @@
 module github.com/francoiscolombo/gomangareader/archive
 
 go 1.18
-
-replace github.com/francoiscolombo/gomangareader/settings => ../settings
-
-replace github.com/francoiscolombo/gomangareader/widget => ../widget
-
-require fyne.io/fyne/v2 v2.3.0
@@ -0,0 +1,155 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+/*
+Limits protect the application against the archives that could exhaust the disk or the memory, like
+zip bombs. a limit set to 0 is not checked.
+*/
+type Limits struct {
+	// MaxTotalSize is the maximum uncompressed size of all the entries, in bytes
+	MaxTotalSize int64
+	// MaxEntries is the maximum number of entries
+	MaxEntries int
+	// MaxCompressionRatio is the maximum ratio between the uncompressed and compressed size of an entry
+	MaxCompressionRatio float64
+	// MaxImageDimension is the maximum width or height of a page, in pixels
+	MaxImageDimension int
+}
+
+// DefaultLimits are large enough for any chapter, even the long strips
+var DefaultLimits = Limits{
+	MaxTotalSize:        2 << 30,
+	MaxEntries:          10000,
+	MaxCompressionRatio: 100,
+	MaxImageDimension:   30000,
+}
+
+// compressionRatioMinSize is the size under which the compression ratio of an entry is not checked
+const compressionRatioMinSize = 1 << 20
+
+var limitsLock sync.RWMutex
+var limits = DefaultLimits
+
+// SetLimits changes the limits checked when archives are opened or extracted
+func SetLimits(l Limits) {
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+	limits = l
+}
+
+func currentLimits() Limits {
+	limitsLock.RLock()
+	defer limitsLock.RUnlock()
+	return limits
+}
+
+// ErrSymlink is reported for an entry that is a symbolic link
+var ErrSymlink = errors.New("symbolic links are not allowed")
+
+// ErrUnsafePath is reported for an entry with an absolute path or a path going out of the archive
+var ErrUnsafePath = errors.New("entry path is not safe")
+
+// LimitError is reported when an archive goes over one of the limits
+type LimitError struct {
+	Archive string
+	Entry   string
+	Limit   string
+	Value   int64
+	Max     int64
+}
+
+func (e *LimitError) Error() string {
+	if e.Entry != "" {
+		return fmt.Sprintf("%s: entry %s is over the %s limit (%d, max %d)", e.Archive, e.Entry, e.Limit, e.Value, e.Max)
+	}
+	return fmt.Sprintf("%s is over the %s limit (%d, max %d)", e.Archive, e.Limit, e.Value, e.Max)
+}
+
+// EntryError is reported when an entry of an archive is rejected
+type EntryError struct {
+	Archive string
+	Entry   string
+	Err     error
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("%s: entry %s is rejected: %s", e.Archive, e.Entry, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+/*
+checkEntries checks the entries declared by an archive before reading any of them: their number, their
+total size, their compression ratio, and that they are plain files with a safe path.
+*/
+func checkEntries(filename string, files []*zip.File) error {
+	l := currentLimits()
+	if l.MaxEntries > 0 && len(files) > l.MaxEntries {
+		return &LimitError{Archive: filename, Limit: "number of entries", Value: int64(len(files)), Max: int64(l.MaxEntries)}
+	}
+	var total int64
+	for _, f := range files {
+		if f.Mode()&os.ModeSymlink != 0 {
+			return &EntryError{Archive: filename, Entry: f.Name, Err: ErrSymlink}
+		}
+		name := strings.ReplaceAll(f.Name, "\\", "/")
+		if path.IsAbs(name) || strings.HasPrefix(path.Clean(name), "../") || path.Clean(name) == ".." || (len(name) > 1 && name[1] == ':') {
+			return &EntryError{Archive: filename, Entry: f.Name, Err: ErrUnsafePath}
+		}
+		size := int64(f.UncompressedSize64)
+		total += size
+		if l.MaxTotalSize > 0 && total > l.MaxTotalSize {
+			return &LimitError{Archive: filename, Limit: "total size", Value: total, Max: l.MaxTotalSize}
+		}
+		if l.MaxCompressionRatio > 0 && size > compressionRatioMinSize {
+			compressed := int64(f.CompressedSize64)
+			if compressed == 0 || float64(size)/float64(compressed) > l.MaxCompressionRatio {
+				ratio := int64(0)
+				if compressed > 0 {
+					ratio = size / compressed
+				}
+				return &LimitError{Archive: filename, Entry: f.Name, Limit: "compression ratio", Value: ratio, Max: int64(l.MaxCompressionRatio)}
+			}
+		}
+	}
+	return nil
+}
+
+/*
+checkImage checks the dimensions declared by a page before it is decoded. the formats that the archive
+package can not read are not checked here.
+*/
+func checkImage(filename, entry string, data []byte) error {
+	l := currentLimits()
+	if l.MaxImageDimension <= 0 {
+		return nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	if cfg.Width > l.MaxImageDimension || cfg.Height > l.MaxImageDimension {
+		dimension := cfg.Width
+		if cfg.Height > dimension {
+			dimension = cfg.Height
+		}
+		return &LimitError{Archive: filename, Entry: entry, Limit: "image dimension", Value: int64(dimension), Max: int64(l.MaxImageDimension)}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// zipEntry is an entry of an archive written by writeZip
+type zipEntry struct {
+	name   string
+	mode   os.FileMode
+	method uint16
+	data   []byte
+}
+
+// writeZip writes an archive with the given entries, without any of the checks of Create
+func writeZip(t *testing.T, entries []zipEntry) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: entry.method}
+		if entry.mode != 0 {
+			header.SetMode(entry.mode)
+		}
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = writer.Write(entry.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "chapter.cbz")
+	if err := os.WriteFile(filename, buffer.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestRejectedEntries(t *testing.T) {
+	page := []byte("page")
+	tests := []struct {
+		name  string
+		entry zipEntry
+		err   error
+	}{
+		{"symbolic link", zipEntry{name: "p1.jpg", mode: os.ModeSymlink | 0777, data: []byte("/etc/passwd")}, ErrSymlink},
+		{"parent folder", zipEntry{name: "../p1.jpg", data: page}, ErrUnsafePath},
+		{"parent folder with backslashes", zipEntry{name: "..\\..\\p1.jpg", data: page}, ErrUnsafePath},
+		{"parent folder after a folder", zipEntry{name: "pages/../../p1.jpg", data: page}, ErrUnsafePath},
+		{"absolute path", zipEntry{name: "/tmp/p1.jpg", data: page}, ErrUnsafePath},
+		{"windows drive", zipEntry{name: "C:/p1.jpg", data: page}, ErrUnsafePath},
+		{"folder", zipEntry{name: "pages/p1.jpg", data: page}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := writeZip(t, []zipEntry{test.entry})
+			checks := map[string]func() error{
+				"Open": func() error {
+					r, err := Open(filename)
+					if err == nil {
+						_ = r.Close()
+					}
+					return err
+				},
+				"Verify": func() error {
+					_, err := Verify(filename)
+					return err
+				},
+				"ReadEntry": func() error {
+					_, err := ReadEntry(filename, test.entry.name)
+					return err
+				},
+				"Unzip": func() error {
+					_, err := Unzip(filename, t.TempDir())
+					return err
+				},
+			}
+			for function, check := range checks {
+				err := check()
+				if test.err == nil {
+					if err != nil {
+						t.Errorf("%s rejected the entry: %s", function, err)
+					}
+					continue
+				}
+				var entryError *EntryError
+				if !errors.Is(err, test.err) || !errors.As(err, &entryError) {
+					t.Errorf("%s returned %v, expected %v", function, err, test.err)
+				}
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	defer SetLimits(DefaultLimits)
+	zeros := make([]byte, 2*compressionRatioMinSize)
+	tests := []struct {
+		name    string
+		limits  Limits
+		entries []zipEntry
+		limit   string
+	}{
+		{
+			name:    "number of entries",
+			limits:  Limits{MaxEntries: 2},
+			entries: []zipEntry{{name: "p1.jpg"}, {name: "p2.jpg"}, {name: "p3.jpg"}},
+			limit:   "number of entries",
+		},
+		{
+			name:    "total size",
+			limits:  Limits{MaxTotalSize: 10},
+			entries: []zipEntry{{name: "p1.jpg", data: make([]byte, 6)}, {name: "p2.jpg", data: make([]byte, 6)}},
+			limit:   "total size",
+		},
+		{
+			name:    "compression ratio",
+			limits:  Limits{MaxCompressionRatio: 100},
+			entries: []zipEntry{{name: "p1.jpg", method: zip.Deflate, data: zeros}},
+			limit:   "compression ratio",
+		},
+		{
+			name:    "no limit",
+			limits:  Limits{},
+			entries: []zipEntry{{name: "p1.jpg", method: zip.Deflate, data: zeros}, {name: "p2.jpg"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetLimits(test.limits)
+			filename := writeZip(t, test.entries)
+			_, err := Verify(filename)
+			if test.limit == "" {
+				if err != nil {
+					t.Errorf("the archive is rejected: %s", err)
+				}
+				return
+			}
+			var limitError *LimitError
+			if !errors.As(err, &limitError) || limitError.Limit != test.limit {
+				t.Errorf("Verify returned %v, expected the %s limit", err, test.limit)
+			}
+		})
+	}
+}
+
+func TestImageDimensionLimit(t *testing.T) {
+	defer SetLimits(DefaultLimits)
+	dir := t.TempDir()
+	files := []string{writePage(t, dir, "p1.png", 16, 24), writePage(t, dir, "p2.png", 64, 24)}
+	filename := filepath.Join(dir, "chapter.cbz")
+	if err := Create(filename, files, nil, CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	SetLimits(Limits{MaxImageDimension: 32})
+	r, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.ReadPage(0); err != nil {
+		t.Errorf("the small page is rejected: %s", err)
+	}
+	var limitError *LimitError
+	if _, err := r.ReadPage(1); !errors.As(err, &limitError) || limitError.Limit != "image dimension" || limitError.Value != 64 {
+		t.Errorf("ReadPage returned %v, expected the image dimension limit", err)
+	}
+}
@@ -0,0 +1,267 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CoMetFileName is the name of the metadata entry written by the CoMet compatible tools
+const CoMetFileName = "CoMet.xml"
+
+/*
+Metadata is the metadata found in an archive, whatever its format: ComicInfo.xml, CoMet.xml or the
+ComicBookInfo json stored in the zip comment. when several formats are present, ComicInfo.xml wins and
+the missing fields are taken from the other ones. Formats lists the formats found, it is empty when the
+archive has no metadata at all.
+*/
+type Metadata struct {
+	Formats   []string
+	Series    string
+	Title     string
+	Number    string
+	Volume    int
+	Summary   string
+	Writer    string
+	Penciller string
+	Genre     string
+	Tags      string
+	AgeRating string
+	Year      int
+	Manga     string
+	Pages     []ComicPageInfo
+}
+
+type coMet struct {
+	XMLName          xml.Name `xml:"comet"`
+	Title            string   `xml:"title"`
+	Description      string   `xml:"description"`
+	Series           string   `xml:"series"`
+	Issue            string   `xml:"issue"`
+	Volume           int      `xml:"volume"`
+	Date             string   `xml:"date"`
+	Genre            []string `xml:"genre"`
+	Writer           []string `xml:"writer"`
+	Penciller        []string `xml:"penciller"`
+	ReadingDirection string   `xml:"readingDirection"`
+}
+
+type comicBookInfo struct {
+	Info struct {
+		Series          string          `json:"series"`
+		Title           string          `json:"title"`
+		Issue           json.RawMessage `json:"issue"`
+		Volume          json.RawMessage `json:"volume"`
+		PublicationYear json.RawMessage `json:"publicationYear"`
+		Comments        string          `json:"comments"`
+		Genre           string          `json:"genre"`
+		Credits         []struct {
+			Person string `json:"person"`
+			Role   string `json:"role"`
+		} `json:"credits"`
+	} `json:"ComicBookInfo/1.0"`
+}
+
+// IsMetadataFile tells if an entry of an archive is a metadata file and not a page
+func IsMetadataFile(name string) bool {
+	base := filepath.Base(name)
+	return strings.EqualFold(base, ComicInfoFileName) || strings.EqualFold(base, CoMetFileName)
+}
+
+/*
+ReadMetadata reads the metadata embedded in an archive. an archive without metadata is not an error,
+the returned Metadata has no format in this case.
+*/
+func ReadMetadata(filename string) (meta Metadata, err error) {
+	r, err := zip.OpenReader(filename)
+	if err != nil {
+		return meta, err
+	}
+	defer closeWith(r, &err)
+	if err = checkEntries(filename, r.File); err != nil {
+		return meta, err
+	}
+	return readMetadata(&r.Reader)
+}
+
+func readMetadata(r *zip.Reader) (meta Metadata, err error) {
+	for _, f := range r.File {
+		switch strings.ToLower(filepath.Base(f.Name)) {
+		case strings.ToLower(ComicInfoFileName):
+			var info ComicInfo
+			if err = readXmlEntry(f, &info); err != nil {
+				return meta, err
+			}
+			meta.merge(Metadata{
+				Formats:   []string{"ComicInfo"},
+				Series:    info.Series,
+				Title:     info.Title,
+				Number:    info.Number,
+				Volume:    info.Volume,
+				Summary:   info.Summary,
+				Writer:    info.Writer,
+				Penciller: info.Penciller,
+				Genre:     info.Genre,
+				Tags:      info.Tags,
+				AgeRating: info.AgeRating,
+				Year:      info.Year,
+				Manga:     info.Manga,
+				Pages:     info.Pages,
+			}, true)
+		case strings.ToLower(CoMetFileName):
+			var info coMet
+			if err = readXmlEntry(f, &info); err != nil {
+				return meta, err
+			}
+			manga := ""
+			if strings.EqualFold(info.ReadingDirection, "rtl") {
+				manga = "YesAndRightToLeft"
+			}
+			meta.merge(Metadata{
+				Formats:   []string{"CoMet"},
+				Series:    info.Series,
+				Title:     info.Title,
+				Number:    info.Issue,
+				Volume:    info.Volume,
+				Summary:   info.Description,
+				Writer:    strings.Join(info.Writer, ", "),
+				Penciller: strings.Join(info.Penciller, ", "),
+				Genre:     strings.Join(info.Genre, ", "),
+				Year:      leadingYear(info.Date),
+				Manga:     manga,
+			}, false)
+		}
+	}
+
+	if strings.Contains(r.Comment, "ComicBookInfo/1.0") {
+		var info comicBookInfo
+		if err = json.Unmarshal([]byte(r.Comment), &info); err != nil {
+			return meta, err
+		}
+		var writers, pencillers []string
+		for _, credit := range info.Info.Credits {
+			switch strings.ToLower(credit.Role) {
+			case "writer":
+				writers = append(writers, credit.Person)
+			case "penciller", "artist":
+				pencillers = append(pencillers, credit.Person)
+			}
+		}
+		volume, _ := strconv.Atoi(rawValue(info.Info.Volume))
+		meta.merge(Metadata{
+			Formats:   []string{"ComicBookInfo"},
+			Series:    info.Info.Series,
+			Title:     info.Info.Title,
+			Number:    rawValue(info.Info.Issue),
+			Volume:    volume,
+			Summary:   info.Info.Comments,
+			Writer:    strings.Join(writers, ", "),
+			Penciller: strings.Join(pencillers, ", "),
+			Genre:     info.Info.Genre,
+			Year:      leadingYear(rawValue(info.Info.PublicationYear)),
+		}, false)
+	}
+	return meta, nil
+}
+
+/*
+PageOrder returns the reading order of the nbPages pages of the archive, as indexes of the pages in the
+archive. the pages declared as deleted are skipped, and the pages not declared keep their place after
+the declared ones.
+*/
+func (meta Metadata) PageOrder(nbPages int) []int {
+	var order []int
+	seen := map[int]bool{}
+	for _, page := range meta.Pages {
+		if page.Image < 0 || page.Image >= nbPages || seen[page.Image] {
+			continue
+		}
+		seen[page.Image] = true
+		if strings.EqualFold(page.Type, "Deleted") {
+			continue
+		}
+		order = append(order, page.Image)
+	}
+	for i := 0; i < nbPages; i++ {
+		if !seen[i] {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// IsDoublePage tells if the page at this index of the archive is declared as a double page
+func (meta Metadata) IsDoublePage(index int) bool {
+	for _, page := range meta.Pages {
+		if page.Image == index {
+			return page.DoublePage
+		}
+	}
+	return false
+}
+
+// merge copies the fields of other, only the missing ones unless override is true
+func (meta *Metadata) merge(other Metadata, override bool) {
+	meta.Formats = append(meta.Formats, other.Formats...)
+	mergeString := func(field *string, value string) {
+		if value != "" && (override || *field == "") {
+			*field = value
+		}
+	}
+	mergeInt := func(field *int, value int) {
+		if value != 0 && (override || *field == 0) {
+			*field = value
+		}
+	}
+	mergeString(&meta.Series, other.Series)
+	mergeString(&meta.Title, other.Title)
+	mergeString(&meta.Number, other.Number)
+	mergeInt(&meta.Volume, other.Volume)
+	mergeString(&meta.Summary, other.Summary)
+	mergeString(&meta.Writer, other.Writer)
+	mergeString(&meta.Penciller, other.Penciller)
+	mergeString(&meta.Genre, other.Genre)
+	mergeString(&meta.Tags, other.Tags)
+	mergeString(&meta.AgeRating, other.AgeRating)
+	mergeInt(&meta.Year, other.Year)
+	mergeString(&meta.Manga, other.Manga)
+	if len(other.Pages) > 0 && (override || len(meta.Pages) == 0) {
+		meta.Pages = other.Pages
+	}
+}
+
+func readXmlEntry(f *zip.File, v interface{}) error {
+	data, err := readEntry(f)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(data, v)
+}
+
+// rawValue returns a json value that can be a string or a number as a string
+func rawValue(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// leadingYear returns the year of a date like 2021-03-04, or 0
+func leadingYear(date string) int {
+	date = strings.TrimSpace(date)
+	if len(date) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+	return year
+}
@@ -0,0 +1,93 @@
+package archive
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+/*
+NaturalLess compares two names the way a human would: the sequences of digits are compared by their
+value, so "p2" comes before "p10". the other characters are compared without case.
+*/
+func NaturalLess(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			// compare the numbers without their leading zeros, the longest one is the biggest
+			si, sj := i, j
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			na, nb := trimZeros(ra[si:i]), trimZeros(rb[sj:j])
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			for k := range na {
+				if na[k] != nb[k] {
+					return na[k] < nb[k]
+				}
+			}
+			continue
+		}
+		ca, cb := unicode.ToLower(ra[i]), unicode.ToLower(rb[j])
+		if ca != cb {
+			return ca < cb
+		}
+		i++
+		j++
+	}
+	if len(ra)-i != len(rb)-j {
+		return len(ra)-i < len(rb)-j
+	}
+	// same natural order, keep a stable result
+	return a < b
+}
+
+func trimZeros(digits []rune) []rune {
+	for len(digits) > 1 && digits[0] == '0' {
+		digits = digits[1:]
+	}
+	return digits
+}
+
+// SortNatural sorts names in natural order
+func SortNatural(names []string) {
+	sort.SliceStable(names, func(i, j int) bool {
+		return NaturalLess(names[i], names[j])
+	})
+}
+
+/*
+pageExtensions are the extensions of the entries considered as pages: the formats of the decoders
+registered by the application, jpeg, png and gif from the standard library, webp and bmp from
+golang.org/x/image, and AVIF, which is kept in the archives but only checked from its header.
+*/
+var pageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+	".bmp":  true,
+	".avif": true,
+}
+
+/*
+IsPage tells if an entry of an archive is a page: an image, that is not hidden and not in a folder
+added by the archiver like __MACOSX. the metadata files are not pages.
+*/
+func IsPage(name string) bool {
+	name = strings.ReplaceAll(name, "\\", "/")
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || strings.EqualFold(part, "__MACOSX") || strings.EqualFold(part, "Thumbs.db") {
+			return false
+		}
+	}
+	return pageExtensions[strings.ToLower(path.Ext(name))]
+}
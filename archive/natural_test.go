@@ -0,0 +1,66 @@
+package archive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		less bool
+	}{
+		{"p2.jpg", "p10.jpg", true},
+		{"p10.jpg", "p2.jpg", false},
+		{"page-002.png", "page-10.png", true},
+		{"page-0010.png", "page-9.png", false},
+		{"001.jpg", "1.jpg", true},
+		{"1.jpg", "001.jpg", false},
+		{"Page1.jpg", "page2.jpg", true},
+		{"page2.jpg", "Page1.jpg", false},
+		{"a", "a1", true},
+		{"a1", "a", false},
+		{"chapter 1.5", "chapter 1.10", true},
+		{"x99999999999999999999.jpg", "x100000000000000000000.jpg", true},
+		{"same.jpg", "same.jpg", false},
+		{"", "a", true},
+		{"a", "", false},
+	}
+	for _, test := range tests {
+		if got := NaturalLess(test.a, test.b); got != test.less {
+			t.Errorf("NaturalLess(%q, %q) = %v, expected %v", test.a, test.b, got, test.less)
+		}
+	}
+}
+
+func TestSortNatural(t *testing.T) {
+	names := []string{"p10.jpg", "p1.jpg", "P3.jpg", "p2.jpg", "cover.jpg"}
+	SortNatural(names)
+	expected := []string{"cover.jpg", "p1.jpg", "p2.jpg", "P3.jpg", "p10.jpg"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("SortNatural returned %v, expected %v", names, expected)
+	}
+}
+
+func TestIsPage(t *testing.T) {
+	tests := []struct {
+		name string
+		page bool
+	}{
+		{"001.jpg", true},
+		{"001.JPEG", true},
+		{"folder/001.png", true},
+		{"folder\\001.webp", true},
+		{"001.bmp", true},
+		{"001.avif", true},
+		{"ComicInfo.xml", false},
+		{".hidden.jpg", false},
+		{"__MACOSX/001.jpg", false},
+		{"notes.txt", false},
+	}
+	for _, test := range tests {
+		if got := IsPage(test.name); got != test.page {
+			t.Errorf("IsPage(%q) = %v, expected %v", test.name, got, test.page)
+		}
+	}
+}
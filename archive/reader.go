@@ -0,0 +1,78 @@
+package archive
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+/*
+Reader gives access to the pages of an archive without extracting it: the archive is opened once, and
+each page is read in memory only when it is needed.
+*/
+type Reader struct {
+	filename string
+	archive  *zip.ReadCloser
+	pages    []*zip.File
+}
+
+// Open opens an archive and lists its pages in natural order, the other entries are ignored
+func Open(filename string) (*Reader, error) {
+	r, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkEntries(filename, r.File); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	var pages []*zip.File
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !IsPage(f.Name) {
+			continue
+		}
+		pages = append(pages, f)
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		return NaturalLess(pages[i].Name, pages[j].Name)
+	})
+	return &Reader{filename: filename, archive: r, pages: pages}, nil
+}
+
+// Pages returns the names of the pages, in natural order
+func (r *Reader) Pages() []string {
+	var names []string
+	for _, f := range r.pages {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+/*
+ReadPage returns the content of a page, index is the position of the page in Pages. the dimensions of
+the page are checked against the limits, so it can be decoded safely.
+*/
+func (r *Reader) ReadPage(index int) ([]byte, error) {
+	if index < 0 || index >= len(r.pages) {
+		return nil, errors.New(fmt.Sprintf("page %d does not exist, the archive has %d pages", index+1, len(r.pages)))
+	}
+	data, err := readEntry(r.pages[index])
+	if err != nil {
+		return nil, err
+	}
+	if err = checkImage(r.filename, r.pages[index].Name, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Metadata returns the metadata embedded in the archive, the page indexes match Pages
+func (r *Reader) Metadata() (Metadata, error) {
+	return readMetadata(&r.archive.Reader)
+}
+
+// Close closes the archive
+func (r *Reader) Close() error {
+	return r.archive.Close()
+}
@@ -1,9 +1,71 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"github.com/francoiscolombo/gomangareader/widget"
+	"log"
+	"os"
 )
 
 func main() {
+	backfill := flag.Bool("backfill-comicinfo", false, "add ComicInfo.xml to the chapters downloaded without it, then exit")
+	force := flag.Bool("force", false, "with -backfill-comicinfo, write the metadata of all the chapters again")
+	verify := flag.Bool("verify-library", false, "check all the chapter archives of the library, then exit")
+	repair := flag.Bool("repair", false, "with -verify-library, move the broken archives to the quarantine and download them again")
+	scan := flag.Bool("scan-library", false, "compare the library folder with the history, then exit")
+	apply := flag.Bool("apply", false, "with -scan-library, forget the missing chapters, adopt the archives recognized and remove the orphaned metadata files")
+	rebuild := flag.Bool("rebuild-history", false, "read the series.json files of the library to rebuild the history, then exit")
+	move := flag.String("move-library", "", "move the library folder to the given path and save its new location, then exit")
+	flag.Parse()
+
+	if *backfill {
+		nbUpdated, err := widget.BackfillComicInfo(*force)
+		log.Printf("ComicInfo.xml written in %d chapters", nbUpdated)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *verify {
+		report, err := widget.VerifyLibrary(*repair)
+		fmt.Println(report)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *scan {
+		report, err := widget.ScanLibrary(*apply)
+		fmt.Println(report)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *rebuild {
+		nbTitles, err := widget.RebuildHistory()
+		fmt.Printf("%d titles read from the library\n", nbTitles)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *move != "" {
+		if err := widget.MoveLibrary(*move); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	widget.ShowLibrary()
 }
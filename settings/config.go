@@ -1,5 +1,10 @@
 package settings
 
+import (
+	"path/filepath"
+	"time"
+)
+
 // Settings is the structure that allowed to store the default configuration and the download history for all the mangas
 type Settings struct {
 	Config  Config  `json:"config"`
@@ -22,11 +27,67 @@ type Config struct {
 	ThumbnailHeight      float32 `json:"thumbnail_height"`
 	ThumbTextHeight      float32 `json:"thumb_text_height"`
 	NbWorkers            int     `json:"nb_workers"`
+	NbWorkersPerHost     int     `json:"nb_workers_per_host"`
+	// BandwidthLimit is the global download rate cap in KB/s, 0 means unlimited
+	BandwidthLimit int `json:"bandwidth_limit"`
+	// ProviderBandwidthLimits caps the download rate of a single provider in KB/s
+	ProviderBandwidthLimits map[string]int `json:"provider_bandwidth_limits"`
+	// DownloadWindows restricts the queued downloads to these time ranges, no window means anytime
+	DownloadWindows []DownloadWindow `json:"download_windows"`
+	// UpdateCheckInterval is the delay in minutes between two background checks for new chapters, 0 disables them
+	UpdateCheckInterval int `json:"update_check_interval"`
+	// AutoDownload is the default for the mangas that do not choose to download new chapters automatically or not
+	AutoDownload bool `json:"auto_download"`
+	// AutoDownloadLimit is the maximum number of chapters queued by an update check, 0 means no limit
+	AutoDownloadLimit int `json:"auto_download_limit"`
+	// NamingProfile is the name of the layout used for the series folders and the chapter archives
+	NamingProfile string `json:"naming_profile"`
+	// FolderTemplate is the series folder template of the custom naming profile
+	FolderTemplate string `json:"folder_template"`
+	// FileTemplate is the chapter archive template of the custom naming profile, without extension
+	FileTemplate string `json:"file_template"`
+	// ArchiveCompression is how the pages of new archives are compressed: auto (store the images), store or deflate
+	ArchiveCompression string `json:"archive_compression"`
+	// ArchiveWorkers is the number of pages compressed in parallel when an archive is created
+	ArchiveWorkers int `json:"archive_workers"`
+	// ArchiveLimits protect against the archives too big to be opened, 0 keeps the default limit
+	ArchiveLimits ArchiveLimits `json:"archive_limits"`
+	// Roots are the other folders of the library, the series are in LibraryPath unless they choose one of them
+	Roots []LibraryRoot `json:"library_roots"`
+	// TrashRetention is the number of days the removed mangas are kept in the trash, 0 keeps the default
+	TrashRetention int `json:"trash_retention"`
+}
+
+// ArchiveLimits are the limits checked when an archive is opened or extracted
+type ArchiveLimits struct {
+	MaxSizeMB           int     `json:"max_size_mb"`
+	MaxEntries          int     `json:"max_entries"`
+	MaxCompressionRatio float64 `json:"max_compression_ratio"`
+	MaxPageDimension    int     `json:"max_page_dimension"`
+}
+
+// DownloadWindow is a daily time range, like 01:00 to 07:00. the range can cross midnight
+type DownloadWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
 }
 
+// MaxDownloadRecords is the number of downloads kept in the downloads log
+const MaxDownloadRecords = 500
+
 // History is the manga download history, so it's an array of all the mangas downloaded
 type History struct {
-	Titles []Manga `json:"titles"`
+	Titles    []Manga          `json:"titles"`
+	Downloads []DownloadRecord `json:"downloads"`
+}
+
+// DownloadRecord is an entry of the downloads log
+type DownloadRecord struct {
+	Title     string    `json:"title"`
+	Name      string    `json:"name"`
+	Chapter   float64   `json:"chapter"`
+	Date      time.Time `json:"date"`
+	Automatic bool      `json:"automatic"`
 }
 
 // Manga keep the download history for every manga that we are subscribing
@@ -45,4 +106,73 @@ type Manga struct {
 	Author        string    `json:"author"`
 	Artist        string    `json:"artist"`
 	Description   string    `json:"description"`
+	// Genres are the genres given by the provider
+	Genres []string `json:"genres"`
+	// Tags are free keywords, given by the provider or added by the user
+	Tags []string `json:"tags"`
+	// ContentRating is the audience of the manga, like "Teen" or "Mature 17+"
+	ContentRating string `json:"content_rating"`
+	// SkipUpdateCheck excludes this manga from the background checks for new chapters
+	SkipUpdateCheck bool `json:"skip_update_check"`
+	// AvailableChapter is the last chapter published by the provider, as seen by the last update check
+	AvailableChapter float64 `json:"available_chapter"`
+	// LastUpdateCheck is the time of the last update check for this manga
+	LastUpdateCheck time.Time `json:"last_update_check"`
+	// AutoDownload enqueues the new chapters found by the update checks, when nil the global default is used
+	AutoDownload *bool `json:"auto_download"`
+	// ChapterTitles are the titles of the chapters, keyed by ChapterKey
+	ChapterTitles map[string]string `json:"chapter_titles"`
+	// ChapterVolumes are the volumes of the chapters, keyed by ChapterKey
+	ChapterVolumes map[string]int `json:"chapter_volumes"`
+	// CustomCover is the cover chosen by the user, it replaces the cover of the provider
+	CustomCover string `json:"custom_cover"`
+	// PageCounts are the number of pages of the downloaded chapters, keyed by ChapterKey
+	PageCounts map[string]int `json:"page_counts"`
+	// Overrides are the details corrected by the user, keyed by their json name, see OverridableFields
+	Overrides map[string]string `json:"overrides"`
+	// ProviderValues are the values given by the provider for the details corrected by the user
+	ProviderValues map[string]string `json:"provider_values"`
+	// Root is the name of the library root of the series folder, empty for the main root
+	Root string `json:"root"`
+	// ChapterFiles are the file names of the downloaded chapters in the series folder, keyed by ChapterKey
+	ChapterFiles map[string]string `json:"chapter_files"`
+}
+
+/*
+SetPageCount records the number of pages of a downloaded chapter. the map is copied, since the copies
+of the manga held by the history and the widgets share it.
+*/
+func (manga *Manga) SetPageCount(chapter float64, nbPages int) {
+	counts := map[string]int{}
+	for k, v := range manga.PageCounts {
+		counts[k] = v
+	}
+	counts[ChapterKey(chapter)] = nbPages
+	manga.PageCounts = counts
+}
+
+/*
+SetChapterFile records the file name of the archive of a downloaded chapter, so the archive is still
+found when the manga is renamed. the map is copied, like the page counts.
+*/
+func (manga *Manga) SetChapterFile(chapter float64, path string) {
+	files := map[string]string{}
+	for k, v := range manga.ChapterFiles {
+		files[k] = v
+	}
+	files[ChapterKey(chapter)] = filepath.Base(path)
+	manga.ChapterFiles = files
+}
+
+// AutoDownloadEnabled tells if the new chapters of this manga must be downloaded automatically
+func (manga Manga) AutoDownloadEnabled(cfg Config) bool {
+	if manga.AutoDownload != nil {
+		return *manga.AutoDownload
+	}
+	return cfg.AutoDownload
+}
+
+// NewChaptersAvailable tells if the last update check found chapters that are not downloaded yet
+func (manga Manga) NewChaptersAvailable() bool {
+	return manga.AvailableChapter > manga.LastChapter
 }
@@ -59,6 +59,7 @@ func (provider MangaReader) FindDetails(libraryPath, title string, lastChapter f
 	var artist string
 	var release string
 	var status string
+	var genres []string
 	// coverurl
 	doc.Find(".imgdesc").Each(func(i int, div *goquery.Selection) {
 		div.Find("img").Each(func(i int, img *goquery.Selection) {
@@ -99,6 +100,8 @@ func (provider MangaReader) FindDetails(libraryPath, title string, lastChapter f
 				status = v
 			} else if strings.HasPrefix(k, "release") {
 				release = v
+			} else if strings.HasPrefix(k, "genre") {
+				genres = ParseList(v)
 			}
 		}
 	}
@@ -124,6 +127,7 @@ func (provider MangaReader) FindDetails(libraryPath, title string, lastChapter f
 		Author:        author,
 		Artist:        artist,
 		Description:   strings.TrimSpace(description),
+		Genres:        genres,
 	}
 	return
 }
@@ -0,0 +1,284 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CustomNamingProfile is the name of the profile using the templates from the configuration
+const CustomNamingProfile = "custom"
+
+/*
+NamingProfile describes how the series folders and the chapter archives are named in the library.
+the templates can use the following placeholders:
+{series} the name of the manga, {slug} the title used by the provider, {provider} the provider,
+{volume} the volume as v01 (empty if unknown), {chapter} the chapter padded on 4 digits,
+{chapter:legacy} the chapter formatted like the first versions of gomangareader, and {chapter_title}.
+*/
+type NamingProfile struct {
+	Name   string `json:"name"`
+	Folder string `json:"folder"`
+	File   string `json:"file"`
+}
+
+// NamingProfiles are the preset profiles, the first one is the default layout
+var NamingProfiles = []NamingProfile{
+	{Name: "default", Folder: "{slug}", File: "{slug}-{chapter:legacy}"},
+	{Name: "komga", Folder: "{series}", File: "{series} {volume} c{chapter}"},
+	{Name: "kavita", Folder: "{series}", File: "{series} {volume} Ch. {chapter}"},
+	{Name: "tachiyomi", Folder: "{series}", File: "Chapter {chapter} {chapter_title}"},
+}
+
+// Naming returns the naming profile selected in the configuration
+func (cfg Config) Naming() NamingProfile {
+	if cfg.NamingProfile == CustomNamingProfile && cfg.FolderTemplate != "" && cfg.FileTemplate != "" {
+		return NamingProfile{Name: CustomNamingProfile, Folder: cfg.FolderTemplate, File: cfg.FileTemplate}
+	}
+	for _, profile := range NamingProfiles {
+		if profile.Name == cfg.NamingProfile {
+			return profile
+		}
+	}
+	return NamingProfiles[0]
+}
+
+// ChapterKey is the key used to store per chapter data, like the chapter titles
+func ChapterKey(chapter float64) string {
+	return strconv.FormatFloat(chapter, 'f', -1, 64)
+}
+
+// FormatChapter pads the integer part of a chapter on 4 digits, and keeps the decimal part only if any
+func FormatChapter(chapter float64) string {
+	parts := strings.SplitN(ChapterKey(chapter), ".", 2)
+	integer, _ := strconv.Atoi(parts[0])
+	if len(parts) == 2 {
+		return fmt.Sprintf("%04d.%s", integer, parts[1])
+	}
+	return fmt.Sprintf("%04d", integer)
+}
+
+// Render replace the placeholders of a template with the values of a manga chapter
+func (profile NamingProfile) Render(template string, manga Manga, chapter float64) string {
+	series := manga.Name
+	if series == "" {
+		series = manga.Title
+	}
+	volume := ""
+	if v, ok := manga.ChapterVolumes[ChapterKey(chapter)]; ok && v > 0 {
+		volume = fmt.Sprintf("v%02d", v)
+	}
+	replacer := strings.NewReplacer(
+		"{series}", series,
+		"{slug}", manga.Title,
+		"{provider}", manga.Provider,
+		"{volume}", volume,
+		"{chapter:legacy}", fmt.Sprintf("%03.1f", chapter),
+		"{chapter}", FormatChapter(chapter),
+		"{chapter_title}", manga.ChapterTitles[ChapterKey(chapter)],
+	)
+	return sanitizeName(replacer.Replace(template))
+}
+
+// sanitizeName removes the characters that are not allowed in a file name, and the separators left by empty placeholders
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 32 {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+	return strings.Trim(name, " -_.")
+}
+
+/*
+NewSeriesPath returns the folder of a manga added to the library, following the naming profile. when
+the template gives an empty name, the folder is named after the title of the manga instead.
+*/
+func NewSeriesPath(cfg Config, manga Manga) string {
+	folder := cfg.Naming().Render(cfg.Naming().Folder, manga, 0)
+	if folder == "" {
+		folder = sanitizeName(manga.Title)
+	}
+	if folder == "" {
+		folder = "series"
+	}
+	return filepath.Join(cfg.RootPath(manga.Root), folder)
+}
+
+/*
+UniqueSeriesPath returns the folder of a manga added to the library, like NewSeriesPath, but never the
+folder of another manga of the history: two mangas with the same name under a {series} profile get
+"Name", then "Name (2)".
+*/
+func UniqueSeriesPath(cfg Settings, manga Manga) string {
+	return uniqueFolder(NewSeriesPath(cfg.Config, manga), func(folder string) bool {
+		for _, m := range cfg.History.Titles {
+			if m.Title != manga.Title && filepath.Clean(SeriesPath(cfg.Config, m)) == filepath.Clean(folder) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// uniqueFolder adds a number to folder until taken returns false
+func uniqueFolder(folder string, taken func(folder string) bool) string {
+	unique := folder
+	for i := 2; taken(unique); i++ {
+		unique = fmt.Sprintf("%s (%d)", folder, i)
+	}
+	return unique
+}
+
+/*
+SeriesPath returns the folder of a manga in the library. it is the folder stored with the manga, so it
+does not change when the manga is renamed; only the mangas without a stored folder follow the naming profile.
+*/
+func SeriesPath(cfg Config, manga Manga) string {
+	if manga.Path != "" {
+		return manga.Path
+	}
+	return NewSeriesPath(cfg, manga)
+}
+
+/*
+ChapterFileName returns the file name of the archive of a chapter: the name recorded when the archive
+was created, or the name given by the naming profile for a chapter not downloaded yet.
+*/
+func ChapterFileName(cfg Config, manga Manga, chapter float64) string {
+	if name := manga.ChapterFiles[ChapterKey(chapter)]; name != "" {
+		return name
+	}
+	naming := cfg.Naming()
+	return naming.Render(naming.File, manga, chapter) + ".cbz"
+}
+
+// ChapterArchivePath returns the path of the cbz archive of a chapter
+func ChapterArchivePath(cfg Config, manga Manga, chapter float64) string {
+	return filepath.Join(SeriesPath(cfg, manga), ChapterFileName(cfg, manga, chapter))
+}
+
+/*
+pinChapterFiles records the folder and the archive names of the mangas downloaded by the previous
+versions, which were computed from the naming profile each time. it returns true if some were recorded.
+the mangas of the offline roots are pinned the next time.
+*/
+func pinChapterFiles(settings *Settings) (pinned bool) {
+	for i, manga := range settings.History.Titles {
+		if !settings.Config.RootAvailable(manga.Root) {
+			continue
+		}
+		if manga.Path == "" {
+			if _, err := os.Stat(NewSeriesPath(settings.Config, manga)); err != nil {
+				continue
+			}
+			manga.Path = NewSeriesPath(settings.Config, manga)
+			pinned = true
+		}
+		for _, chapter := range manga.Chapters {
+			if manga.ChapterFiles[ChapterKey(chapter)] != "" {
+				continue
+			}
+			path := ChapterArchivePath(settings.Config, manga, chapter)
+			if _, err := os.Stat(path); err == nil {
+				manga.SetChapterFile(chapter, path)
+				pinned = true
+			}
+		}
+		settings.History.Titles[i] = manga
+	}
+	return pinned
+}
+
+// ThumbnailPath returns the path of the thumbnail of a chapter, it does not depend on the naming profile
+func ThumbnailPath(cfg Config, manga Manga, chapter float64) string {
+	return filepath.FromSlash(fmt.Sprintf("%s/.metadata/%s-%03.1f.jpg", cfg.LibraryPath, manga.Title, chapter))
+}
+
+/*
+RenameLibrary moves all the chapter archives of the library from the current naming profile to the
+given one, then saves the new profile. if a chapter can not be moved, the archives already moved are
+put back where they were, the current profile is kept and the error is returned.
+*/
+func RenameLibrary(cfg Settings, profile NamingProfile) (newSettings Settings, err error) {
+	for _, manga := range cfg.History.Titles {
+		if !cfg.Config.RootAvailable(manga.Root) {
+			return cfg, errors.New(fmt.Sprintf("the library root %s of %s is offline, the library can not be renamed", manga.RootName(), manga.Name))
+		}
+	}
+	target := cfg.Config
+	target.NamingProfile = profile.Name
+	if profile.Name == CustomNamingProfile {
+		target.FolderTemplate = profile.Folder
+		target.FileTemplate = profile.File
+	}
+	naming := target.Naming()
+	// renamed are the archives moved so far as old and new path pairs, created the folders created
+	var renamed [][2]string
+	var created []string
+	rollback := func(cause error) (Settings, error) {
+		for i := len(renamed) - 1; i >= 0; i-- {
+			if e := os.Rename(renamed[i][1], renamed[i][0]); e != nil {
+				log.Printf("Error when trying to move %s back to %s: %s", renamed[i][1], renamed[i][0], e)
+			}
+		}
+		for i := len(created) - 1; i >= 0; i-- {
+			_ = os.Remove(created[i])
+		}
+		return cfg, errors.New(fmt.Sprintf("the library was not renamed, %s", cause))
+	}
+	var oldFolders []Manga
+	// assigned are the series folders of the new layout, so two mangas never share one
+	assigned := map[string]bool{}
+	newSettings = cfg
+	newSettings.Config = target
+	newSettings.History.Titles = nil
+	for _, manga := range cfg.History.Titles {
+		oldFolder := SeriesPath(cfg.Config, manga)
+		newFolder := uniqueFolder(NewSeriesPath(target, manga), func(folder string) bool {
+			return assigned[filepath.Clean(folder)]
+		})
+		assigned[filepath.Clean(newFolder)] = true
+		for _, chapter := range manga.Chapters {
+			oldPath := ChapterArchivePath(cfg.Config, manga, chapter)
+			newPath := filepath.Join(newFolder, naming.Render(naming.File, manga, chapter)+".cbz")
+			if _, e := os.Stat(oldPath); e != nil {
+				continue
+			}
+			if oldPath != newPath {
+				if _, e := os.Stat(newPath); e == nil {
+					return rollback(errors.New(fmt.Sprintf("%s already exists", newPath)))
+				}
+				if _, e := os.Stat(filepath.Dir(newPath)); e != nil {
+					if e = os.MkdirAll(filepath.Dir(newPath), os.ModePerm); e != nil {
+						return rollback(e)
+					}
+					created = append(created, filepath.Dir(newPath))
+				}
+				if e := os.Rename(oldPath, newPath); e != nil {
+					return rollback(e)
+				}
+				renamed = append(renamed, [2]string{oldPath, newPath})
+			}
+			manga.SetChapterFile(chapter, newPath)
+		}
+		if oldFolder != newFolder {
+			oldFolders = append(oldFolders, manga)
+		}
+		manga.Path = newFolder
+		newSettings.History.Titles = append(newSettings.History.Titles, manga)
+	}
+	// the old folders are removed only if they are empty now, their sidecars are written again in the new ones
+	for _, manga := range oldFolders {
+		_ = os.Remove(SidecarPath(cfg.Config, manga))
+		_ = os.Remove(SeriesPath(cfg.Config, manga))
+	}
+	WriteSettings(newSettings)
+	return newSettings, nil
+}
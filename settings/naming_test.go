@@ -0,0 +1,179 @@
+package settings
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatChapter(t *testing.T) {
+	tests := []struct {
+		chapter  float64
+		expected string
+	}{
+		{0, "0000"},
+		{1, "0001"},
+		{1.5, "0001.5"},
+		{12.25, "0012.25"},
+		{1234, "1234"},
+		{12345, "12345"},
+	}
+	for _, test := range tests {
+		if got := FormatChapter(test.chapter); got != test.expected {
+			t.Errorf("FormatChapter(%v) = %q, expected %q", test.chapter, got, test.expected)
+		}
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"One Piece", "One Piece"},
+		{`What? A/B: "C" <D> | E*`, "What AB C D E"},
+		{"  spaced \t  name  ", "spaced name"},
+		{"Title - ", "Title"},
+		{"-_Title._", "Title"},
+		{".", ""},
+		{"..", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := sanitizeName(test.name); got != test.expected {
+			t.Errorf("sanitizeName(%q) = %q, expected %q", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	manga := Manga{
+		Title:          "one-piece",
+		Name:           "One Piece",
+		Provider:       "mangareader.cc",
+		ChapterVolumes: map[string]int{"12": 3},
+		ChapterTitles:  map[string]string{"12": "Romance Dawn: part 1?"},
+	}
+	profile := func(name string) NamingProfile {
+		return Config{NamingProfile: name}.Naming()
+	}
+	tests := []struct {
+		profile  NamingProfile
+		template string
+		manga    Manga
+		chapter  float64
+		expected string
+	}{
+		{profile("default"), profile("default").Folder, manga, 0, "one-piece"},
+		{profile("default"), profile("default").File, manga, 12, "one-piece-12.0"},
+		{profile("default"), profile("default").File, manga, 12.5, "one-piece-12.5"},
+		{profile("komga"), profile("komga").Folder, manga, 0, "One Piece"},
+		{profile("komga"), profile("komga").File, manga, 12, "One Piece v03 c0012"},
+		{profile("komga"), profile("komga").File, manga, 13, "One Piece c0013"},
+		{profile("kavita"), profile("kavita").File, manga, 12, "One Piece v03 Ch. 0012"},
+		{profile("tachiyomi"), profile("tachiyomi").File, manga, 12, "Chapter 0012 Romance Dawn part 1"},
+		{profile("tachiyomi"), profile("tachiyomi").File, manga, 13, "Chapter 0013"},
+		{profile("komga"), "{series}", Manga{Title: "slug-only"}, 0, "slug-only"},
+		{profile("komga"), "{provider}/{slug}", manga, 0, "mangareader.ccone-piece"},
+	}
+	for _, test := range tests {
+		if got := test.profile.Render(test.template, test.manga, test.chapter); got != test.expected {
+			t.Errorf("Render(%q, %q, %v) = %q, expected %q", test.template, test.manga.Title, test.chapter, got, test.expected)
+		}
+	}
+}
+
+func TestNewSeriesPath(t *testing.T) {
+	cfg := Config{LibraryPath: "/library", NamingProfile: "komga"}
+	tests := []struct {
+		manga    Manga
+		expected string
+	}{
+		{Manga{Title: "one-piece", Name: "One Piece"}, "/library/One Piece"},
+		{Manga{Title: "dots", Name: ".."}, "/library/dots"},
+		{Manga{Title: "slashes", Name: "///"}, "/library/slashes"},
+		{Manga{Title: "..", Name: "."}, "/library/series"},
+	}
+	for _, test := range tests {
+		if got := NewSeriesPath(cfg, test.manga); got != filepath.FromSlash(test.expected) {
+			t.Errorf("NewSeriesPath(%q) = %q, expected %q", test.manga.Name, got, test.expected)
+		}
+	}
+}
+
+func TestUniqueSeriesPath(t *testing.T) {
+	cfg := Settings{Config: Config{LibraryPath: "/library", NamingProfile: "komga"}}
+	manga := Manga{Title: "same-3", Name: "Same"}
+	if got := UniqueSeriesPath(cfg, manga); got != filepath.FromSlash("/library/Same") {
+		t.Errorf("UniqueSeriesPath = %q in an empty library", got)
+	}
+	cfg.History.Titles = []Manga{
+		{Title: "same-1", Name: "Same", Path: filepath.FromSlash("/library/Same")},
+		{Title: "same-2", Name: "Same", Path: filepath.FromSlash("/library/Same (2)")},
+	}
+	if got := UniqueSeriesPath(cfg, manga); got != filepath.FromSlash("/library/Same (3)") {
+		t.Errorf("UniqueSeriesPath = %q, expected /library/Same (3)", got)
+	}
+	// the folder of the manga itself is not taken
+	if got := UniqueSeriesPath(cfg, cfg.History.Titles[0]); got != filepath.FromSlash("/library/Same") {
+		t.Errorf("UniqueSeriesPath = %q for a manga of the history, expected its folder", got)
+	}
+}
+
+func TestRenameLibrary(t *testing.T) {
+	cfg := testLibrary(t)
+	for _, title := range []string{"same-1", "same-2"} {
+		manga := Manga{Title: title, Name: "Same", Chapters: []float64{1}}
+		manga.Path = NewSeriesPath(cfg.Config, manga)
+		writeFile(t, ChapterArchivePath(cfg.Config, manga, 1))
+		cfg.History.Titles = append(cfg.History.Titles, manga)
+	}
+
+	renamed, err := RenameLibrary(cfg, NamingProfile{Name: "komga"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Same/Same c0001.cbz", "Same (2)/Same c0001.cbz"}
+	for i, manga := range renamed.History.Titles {
+		path := ChapterArchivePath(renamed.Config, manga, 1)
+		if path != filepath.Join(cfg.Config.LibraryPath, filepath.FromSlash(expected[i])) || !exists(path) {
+			t.Errorf("the chapter of %s is %s, expected %s", manga.Title, path, expected[i])
+		}
+		if exists(filepath.Join(cfg.Config.LibraryPath, manga.Title)) {
+			t.Errorf("the old folder of %s is still there", manga.Title)
+		}
+	}
+}
+
+func TestRenameLibraryRollback(t *testing.T) {
+	cfg := testLibrary(t)
+	var archives []string
+	for _, title := range []string{"first", "second"} {
+		manga := Manga{Title: title, Name: title + " name", Chapters: []float64{1, 2}}
+		manga.Path = NewSeriesPath(cfg.Config, manga)
+		for _, chapter := range manga.Chapters {
+			archives = append(archives, writeFile(t, ChapterArchivePath(cfg.Config, manga, chapter)))
+		}
+		cfg.History.Titles = append(cfg.History.Titles, manga)
+	}
+	// the last chapter can not be renamed, its new name is taken
+	taken := writeFile(t, filepath.Join(cfg.Config.LibraryPath, "second name", "second name c0002.cbz"))
+
+	renamed, err := RenameLibrary(cfg, NamingProfile{Name: "komga"})
+	if err == nil {
+		t.Fatal("the library was renamed over an existing archive")
+	}
+	if renamed.Config.NamingProfile != cfg.Config.NamingProfile || renamed.History.Titles[0].Path != cfg.History.Titles[0].Path {
+		t.Errorf("the settings changed, the naming profile is %q", renamed.Config.NamingProfile)
+	}
+	for _, archive := range archives {
+		if !exists(archive) {
+			t.Errorf("%s was not moved back", archive)
+		}
+	}
+	if !exists(taken) {
+		t.Errorf("%s was removed", taken)
+	}
+	if exists(filepath.Join(cfg.Config.LibraryPath, "first name")) {
+		t.Error("the folder created for the first manga is still there")
+	}
+}
@@ -0,0 +1,261 @@
+package settings
+
+import (
+	"sort"
+	"strings"
+)
+
+// OverridableFields are the details of a manga that the user can correct, by their json name
+var OverridableFields = []string{"name", "alternate_name", "year_of_release", "status", "author", "artist", "description", "genres", "tags", "content_rating"}
+
+// Detail returns the value of the detail of a manga with the given json name, the lists are separated by commas
+func (manga Manga) Detail(name string) string {
+	switch name {
+	case "name":
+		return manga.Name
+	case "alternate_name":
+		return manga.AlternateName
+	case "year_of_release":
+		return manga.YearOfRelease
+	case "status":
+		return manga.Status
+	case "author":
+		return manga.Author
+	case "artist":
+		return manga.Artist
+	case "description":
+		return manga.Description
+	case "genres":
+		return strings.Join(manga.Genres, ", ")
+	case "tags":
+		return strings.Join(manga.Tags, ", ")
+	case "content_rating":
+		return manga.ContentRating
+	}
+	return ""
+}
+
+// SetDetail changes the detail of a manga with the given json name, it returns false if the detail is unknown
+func (manga *Manga) SetDetail(name, value string) bool {
+	switch name {
+	case "name":
+		manga.Name = value
+	case "alternate_name":
+		manga.AlternateName = value
+	case "year_of_release":
+		manga.YearOfRelease = value
+	case "status":
+		manga.Status = value
+	case "author":
+		manga.Author = value
+	case "artist":
+		manga.Artist = value
+	case "description":
+		manga.Description = value
+	case "genres":
+		manga.Genres = ParseList(value)
+	case "tags":
+		manga.Tags = ParseList(value)
+	case "content_rating":
+		manga.ContentRating = value
+	default:
+		return false
+	}
+	return true
+}
+
+// ParseList splits a list separated by commas, the empty and duplicate values are removed
+func ParseList(value string) []string {
+	var list []string
+	seen := map[string]bool{}
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[strings.ToLower(v)] {
+			continue
+		}
+		seen[strings.ToLower(v)] = true
+		list = append(list, v)
+	}
+	return list
+}
+
+// keywords returns the content rating, the genres and the tags of a manga
+func (manga Manga) keywords() []string {
+	keywords := append([]string{manga.ContentRating}, manga.Genres...)
+	return append(keywords, manga.Tags...)
+}
+
+/*
+MatchesFilter tells if a manga has the given genre, tag or content rating, ignoring the case. an empty
+filter matches all the mangas.
+*/
+func (manga Manga) MatchesFilter(filter string) bool {
+	if filter == "" {
+		return true
+	}
+	for _, v := range manga.keywords() {
+		if strings.EqualFold(v, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+HasGenre tells if a manga has the given genre, ignoring the case. an empty genre matches all the mangas.
+the providers only give the genres, so this is the filter of the search results.
+*/
+func (manga Manga) HasGenre(genre string) bool {
+	if genre == "" {
+		return true
+	}
+	for _, v := range manga.Genres {
+		if strings.EqualFold(v, genre) {
+			return true
+		}
+	}
+	return false
+}
+
+// Keywords returns the genres, tags and content ratings of the given mangas, sorted and without duplicates
+func Keywords(titles []Manga) []string {
+	var keywords []string
+	for _, manga := range titles {
+		keywords = append(keywords, manga.keywords()...)
+	}
+	return sortedUnique(keywords)
+}
+
+// Genres returns the genres of the given mangas, sorted and without duplicates
+func Genres(titles []Manga) []string {
+	var genres []string
+	for _, manga := range titles {
+		genres = append(genres, manga.Genres...)
+	}
+	return sortedUnique(genres)
+}
+
+// sortedUnique returns the non empty values sorted and without duplicates, ignoring the case
+func sortedUnique(values []string) []string {
+	var unique []string
+	seen := map[string]bool{}
+	for _, v := range values {
+		if v == "" || seen[strings.ToLower(v)] {
+			continue
+		}
+		seen[strings.ToLower(v)] = true
+		unique = append(unique, v)
+	}
+	sort.Slice(unique, func(i, j int) bool {
+		return strings.ToLower(unique[i]) < strings.ToLower(unique[j])
+	})
+	return unique
+}
+
+func isOverridable(name string) bool {
+	for _, field := range OverridableFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOverridden tells if a detail of the manga was corrected by the user
+func (manga Manga) IsOverridden(name string) bool {
+	_, ok := manga.Overrides[name]
+	return ok
+}
+
+// ProviderValue returns the value of a detail as given by the provider, before the corrections of the user
+func (manga Manga) ProviderValue(name string) string {
+	if value, ok := manga.ProviderValues[name]; ok {
+		return value
+	}
+	return manga.Detail(name)
+}
+
+/*
+SetOverride corrects a detail of the manga. the value of the provider is kept, so the correction can be
+removed later, and a value equal to the one of the provider removes the correction.
+the maps are copied, since the copies of the manga held by the history and the widgets share them.
+*/
+func (manga *Manga) SetOverride(name, value string) {
+	if !isOverridable(name) {
+		return
+	}
+	if name == "genres" || name == "tags" {
+		value = strings.Join(ParseList(value), ", ")
+	}
+	providerValue := manga.ProviderValue(name)
+	if value == providerValue {
+		manga.ResetOverride(name)
+		return
+	}
+	overrides := map[string]string{}
+	for k, v := range manga.Overrides {
+		overrides[k] = v
+	}
+	overrides[name] = value
+	providerValues := map[string]string{}
+	for k, v := range manga.ProviderValues {
+		providerValues[k] = v
+	}
+	providerValues[name] = providerValue
+	manga.Overrides = overrides
+	manga.ProviderValues = providerValues
+	manga.SetDetail(name, value)
+}
+
+// ResetOverride removes the correction of a detail, the value of the provider is used again
+func (manga *Manga) ResetOverride(name string) {
+	if !manga.IsOverridden(name) {
+		return
+	}
+	manga.SetDetail(name, manga.ProviderValue(name))
+	overrides := map[string]string{}
+	for k, v := range manga.Overrides {
+		if k != name {
+			overrides[k] = v
+		}
+	}
+	providerValues := map[string]string{}
+	for k, v := range manga.ProviderValues {
+		if k != name {
+			providerValues[k] = v
+		}
+	}
+	manga.Overrides = overrides
+	manga.ProviderValues = providerValues
+}
+
+// ResetOverrides removes all the corrections of the user
+func (manga *Manga) ResetOverrides() {
+	for _, name := range OverridableFields {
+		manga.ResetOverride(name)
+	}
+}
+
+/*
+ApplyOverrides merges the corrections of the user over the details just read from the provider. the
+values of the provider are kept for the corrected details, so they can be displayed and restored.
+*/
+func ApplyOverrides(manga Manga, overrides map[string]string) Manga {
+	manga.Overrides = nil
+	manga.ProviderValues = nil
+	for _, name := range OverridableFields {
+		value, ok := overrides[name]
+		if !ok {
+			continue
+		}
+		// the correction is kept even if the provider has the same value now, it may change again
+		if manga.Overrides == nil {
+			manga.Overrides = map[string]string{}
+			manga.ProviderValues = map[string]string{}
+		}
+		manga.ProviderValues[name] = manga.Detail(name)
+		manga.Overrides[name] = value
+		manga.SetDetail(name, value)
+	}
+	return manga
+}
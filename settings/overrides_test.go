@@ -0,0 +1,41 @@
+package settings
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHasGenre(t *testing.T) {
+	manga := Manga{Genres: []string{"Action", "Comedy"}, Tags: []string{"Ninja"}, ContentRating: "Safe"}
+	tests := []struct {
+		genre    string
+		expected bool
+	}{
+		{"", true},
+		{"action", true},
+		{"Comedy", true},
+		{"Ninja", false},
+		{"Safe", false},
+		{"Drama", false},
+	}
+	for _, test := range tests {
+		if got := manga.HasGenre(test.genre); got != test.expected {
+			t.Errorf("HasGenre(%q) = %v, expected %v", test.genre, got, test.expected)
+		}
+	}
+}
+
+func TestGenres(t *testing.T) {
+	titles := []Manga{
+		{Genres: []string{"Comedy", "action"}, Tags: []string{"Ninja"}},
+		{Genres: []string{"Action", "", "Drama"}, ContentRating: "Safe"},
+	}
+	expected := []string{"action", "Comedy", "Drama"}
+	if got := Genres(titles); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Genres() = %q, expected %q", got, expected)
+	}
+	expected = []string{"action", "Comedy", "Drama", "Ninja", "Safe"}
+	if got := Keywords(titles); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Keywords() = %q, expected %q", got, expected)
+	}
+}
@@ -0,0 +1,226 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+relativeToLibrary returns a path relative to the library, with slashes, so the settings still work when
+the library is moved or shared between machines. the paths out of the library are kept absolute.
+*/
+func relativeToLibrary(libraryPath, path string) string {
+	if path == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	rel, err := filepath.Rel(libraryPath, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// resolveInLibrary returns the absolute path of a path relative to the library
+func resolveInLibrary(libraryPath, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(libraryPath, filepath.FromSlash(path))
+}
+
+/*
+relativePaths returns the manga with its paths relative to the library, as they are stored. the series
+folder is relative to the root of the manga, the covers to the main root.
+*/
+func (manga Manga) relativePaths(cfg Config) Manga {
+	manga.Path = relativeToLibrary(cfg.RootPath(manga.Root), manga.Path)
+	manga.CoverPath = relativeToLibrary(cfg.LibraryPath, manga.CoverPath)
+	manga.CustomCover = relativeToLibrary(cfg.LibraryPath, manga.CustomCover)
+	return manga
+}
+
+// absolutePaths returns the manga with its paths resolved in the library, as they are used
+func (manga Manga) absolutePaths(cfg Config) Manga {
+	manga.Path = resolveInLibrary(cfg.RootPath(manga.Root), manga.Path)
+	manga.CoverPath = resolveInLibrary(cfg.LibraryPath, manga.CoverPath)
+	manga.CustomCover = resolveInLibrary(cfg.LibraryPath, manga.CustomCover)
+	return manga
+}
+
+// storedSettings returns the settings as they are written in the settings file
+func storedSettings(settings Settings) Settings {
+	titles := make([]Manga, len(settings.History.Titles))
+	for i, manga := range settings.History.Titles {
+		titles[i] = manga.relativePaths(settings.Config)
+	}
+	settings.History.Titles = titles
+	return settings
+}
+
+/*
+resolvePaths makes the paths of the settings file absolute. it returns true if some paths of the
+library were stored as absolute paths, like the previous versions did, so they must be migrated.
+*/
+func resolvePaths(settings *Settings) (migrate bool) {
+	for i, manga := range settings.History.Titles {
+		stored := manga.relativePaths(settings.Config)
+		if stored.Path != manga.Path || stored.CoverPath != manga.CoverPath || stored.CustomCover != manga.CustomCover {
+			migrate = true
+		}
+		settings.History.Titles[i] = manga.absolutePaths(settings.Config)
+	}
+	return migrate
+}
+
+/*
+MoveLibrary moves the main root of the library to target, then saves its new location. the other
+roots of the library are not moved.
+*/
+func MoveLibrary(cfg Settings, target string, progress func(done, total int)) (newSettings Settings, err error) {
+	source := filepath.Clean(cfg.Config.LibraryPath)
+	target, err = filepath.Abs(target)
+	if err != nil {
+		return cfg, err
+	}
+	if target == source {
+		return cfg, errors.New("the library is already there")
+	}
+	newSettings = cfg
+	newSettings.Config.LibraryPath = target
+	if err = CheckLibraryRoots(newSettings.Config); err != nil {
+		return cfg, err
+	}
+	if err = moveFolder(source, target, progress); err != nil {
+		return cfg, err
+	}
+
+	newSettings = RelocateLibrary(cfg, target)
+	WriteSettings(newSettings)
+	if _, e := os.Stat(source); e == nil {
+		if e = os.RemoveAll(source); e != nil {
+			err = errors.New(fmt.Sprintf("the library has been copied to %s, but %s could not be removed: %s", target, source, e))
+		}
+	}
+	return newSettings, err
+}
+
+/*
+RelocateLibrary returns the settings of a library moved to libraryPath: the paths of the mangas inside
+the library follow it, the paths outside of the library are kept. the files are not moved.
+*/
+func RelocateLibrary(cfg Settings, libraryPath string) (newSettings Settings) {
+	newSettings = cfg
+	newSettings.Config.LibraryPath = libraryPath
+	newSettings.History.Titles = nil
+	for _, manga := range cfg.History.Titles {
+		manga = manga.relativePaths(cfg.Config).absolutePaths(newSettings.Config)
+		newSettings.History.Titles = append(newSettings.History.Titles, manga)
+	}
+	return
+}
+
+/*
+moveFolder moves a folder of the library to target, an empty or missing folder. the folder is renamed
+when possible, otherwise its files are copied one by one and progress is called after each of them; if
+a copy fails, the files already copied are removed and the folder stays where it is. the source folder
+of a copy is not removed.
+*/
+func moveFolder(source, target string, progress func(done, total int)) error {
+	if strings.HasPrefix(target, source+string(filepath.Separator)) || strings.HasPrefix(source, target+string(filepath.Separator)) {
+		return errors.New(fmt.Sprintf("%s can not be moved to %s, one folder is inside the other", source, target))
+	}
+	if info, err := os.Lstat(target); err == nil {
+		if !info.IsDir() {
+			return errors.New(fmt.Sprintf("%s already exists and is not a folder", target))
+		}
+		if entries, e := os.ReadDir(target); e != nil || len(entries) > 0 {
+			return errors.New(fmt.Sprintf("%s is not empty", target))
+		}
+		// the empty folder is replaced by the source folder
+		if err = os.Remove(target); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(source, target); err != nil {
+		// another disk, the files must be copied
+		if err = copyLibrary(source, target, progress); err != nil {
+			if e := os.RemoveAll(target); e != nil {
+				log.Printf("Error when trying to remove the partial copy %s: %s", target, e)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// copyLibrary copies all the files of a folder of the library, including the hidden folders
+func copyLibrary(source, target string, progress func(done, total int)) error {
+	var files []string
+	err := filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, file := range files {
+		rel, err := filepath.Rel(source, file)
+		if err != nil {
+			return err
+		}
+		if err = copyFile(file, filepath.Join(target, rel)); err != nil {
+			return errors.New(fmt.Sprintf("Error when trying to copy %s: %s", file, err))
+		}
+		if progress != nil {
+			progress(i+1, len(files))
+		}
+	}
+	return nil
+}
+
+// copyFile copies a file, with its modification time since the thumbnails depend on it
+func copyFile(source, target string) (err error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written != info.Size() {
+		err = errors.New(fmt.Sprintf("%d bytes copied, %d expected", written, info.Size()))
+	}
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(target, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,139 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRelativeToLibrary(t *testing.T) {
+	library := filepath.FromSlash("/data/mangas")
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"", ""},
+		{"naruto", "naruto"},
+		{filepath.FromSlash("/data/mangas/naruto"), "naruto"},
+		{filepath.FromSlash("/data/mangas/.metadata/naruto-cover.jpg"), ".metadata/naruto-cover.jpg"},
+		{filepath.FromSlash("/data/mangas"), "."},
+		{filepath.FromSlash("/data/other/naruto"), filepath.FromSlash("/data/other/naruto")},
+		{filepath.FromSlash("/data/mangas2/naruto"), filepath.FromSlash("/data/mangas2/naruto")},
+	}
+	for _, test := range tests {
+		if got := relativeToLibrary(library, test.path); got != test.expected {
+			t.Errorf("relativeToLibrary(%q) = %q, expected %q", test.path, got, test.expected)
+		}
+	}
+}
+
+func TestResolveInLibrary(t *testing.T) {
+	library := filepath.FromSlash("/data/mangas")
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"", ""},
+		{"naruto", filepath.FromSlash("/data/mangas/naruto")},
+		{".metadata/naruto-cover.jpg", filepath.FromSlash("/data/mangas/.metadata/naruto-cover.jpg")},
+		{filepath.FromSlash("/data/other/naruto"), filepath.FromSlash("/data/other/naruto")},
+	}
+	for _, test := range tests {
+		if got := resolveInLibrary(library, test.path); got != test.expected {
+			t.Errorf("resolveInLibrary(%q) = %q, expected %q", test.path, got, test.expected)
+		}
+	}
+}
+
+func TestResolvePaths(t *testing.T) {
+	cfg := Settings{Config: Config{LibraryPath: filepath.FromSlash("/data/mangas")}}
+	cfg.History.Titles = []Manga{{Title: "naruto", Path: "naruto", CoverPath: ".metadata/naruto-cover.jpg"}}
+	if resolvePaths(&cfg) {
+		t.Error("resolvePaths() migrates relative paths")
+	}
+	if manga := cfg.History.Titles[0]; manga.Path != filepath.FromSlash("/data/mangas/naruto") || manga.CoverPath != filepath.FromSlash("/data/mangas/.metadata/naruto-cover.jpg") {
+		t.Errorf("resolvePaths() = %+v, expected absolute paths", manga)
+	}
+	stored := storedSettings(cfg).History.Titles[0]
+	if stored.Path != "naruto" || stored.CoverPath != ".metadata/naruto-cover.jpg" {
+		t.Errorf("storedSettings() = %+v, expected relative paths", stored)
+	}
+	// the previous versions stored absolute paths
+	cfg.History.Titles = []Manga{{Title: "naruto", Path: filepath.FromSlash("/data/mangas/naruto")}}
+	if !resolvePaths(&cfg) {
+		t.Error("resolvePaths() does not migrate absolute paths")
+	}
+}
+
+func TestRelocateLibrary(t *testing.T) {
+	cfg := Settings{Config: Config{LibraryPath: filepath.FromSlash("/data/mangas")}}
+	cfg.History.Titles = []Manga{{Title: "naruto", Path: filepath.FromSlash("/data/mangas/naruto"),
+		CustomCover: filepath.FromSlash("/pictures/naruto.png")}}
+	relocated := RelocateLibrary(cfg, filepath.FromSlash("/backup/mangas"))
+	manga := relocated.History.Titles[0]
+	if manga.Path != filepath.FromSlash("/backup/mangas/naruto") || manga.CustomCover != filepath.FromSlash("/pictures/naruto.png") {
+		t.Errorf("RelocateLibrary() = %+v, expected the series folder in the new library and the custom cover kept", manga)
+	}
+	if cfg.History.Titles[0].Path != filepath.FromSlash("/data/mangas/naruto") {
+		t.Error("RelocateLibrary() changed the settings given")
+	}
+}
+
+func TestMoveFolder(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source")
+	file := writeFile(t, filepath.Join(source, "naruto", "naruto-001.cbz"))
+	writeFile(t, filepath.Join(dir, "full", "file"))
+	writeFile(t, filepath.Join(dir, "file"))
+
+	// the folders inside the source or containing it, the folders not empty and the files are refused
+	for _, target := range []string{filepath.Join(source, "inside"), dir, filepath.Join(dir, "full"), filepath.Join(dir, "file")} {
+		if err := moveFolder(source, target, nil); err == nil {
+			t.Errorf("moveFolder(%q) moved the folder", target)
+		}
+	}
+	if !exists(file) {
+		t.Fatal("a failed move changed the source folder")
+	}
+
+	target := filepath.Join(dir, "empty")
+	if err := os.MkdirAll(target, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := moveFolder(source, target, nil); err != nil {
+		t.Fatal(err)
+	}
+	if exists(source) || !exists(filepath.Join(target, "naruto", "naruto-001.cbz")) {
+		t.Error("moveFolder() did not move the folder in the empty target")
+	}
+}
+
+func TestCopyLibrary(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source")
+	file := writeFile(t, filepath.Join(source, ".metadata", "naruto-cover.jpg"))
+	writeFile(t, filepath.Join(source, "naruto", "naruto-001.cbz"))
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	var done, total int
+	target := filepath.Join(dir, "target")
+	if err := copyLibrary(source, target, func(d, t int) { done, total = d, t }); err != nil {
+		t.Fatal(err)
+	}
+	if done != 2 || total != 2 {
+		t.Errorf("the progress is %d/%d, expected 2/2", done, total)
+	}
+	info, err := os.Stat(filepath.Join(target, ".metadata", "naruto-cover.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("the copy is modified at %s, expected %s", info.ModTime(), modTime)
+	}
+	if !exists(filepath.Join(target, "naruto", "naruto-001.cbz")) || !exists(file) {
+		t.Error("copyLibrary() did not copy all the files, or removed the source")
+	}
+}
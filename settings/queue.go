@@ -0,0 +1,47 @@
+package settings
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// QueuedChapter is a chapter waiting in the download queue, Automatic tells if it was queued by the update checker
+type QueuedChapter struct {
+	Title     string  `json:"title"`
+	Chapter   float64 `json:"chapter"`
+	Automatic bool    `json:"automatic"`
+}
+
+// QueuePath returns the file keeping the download queue between two sessions, next to the settings file
+func QueuePath() string {
+	return filepath.Join(filepath.Dir(getSettingsPath()), ".gomangareader-queue.json")
+}
+
+// ReadQueue returns the chapters left in the download queue by the last session
+func ReadQueue() (chapters []QueuedChapter, err error) {
+	data, err := os.ReadFile(QueuePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &chapters)
+	return chapters, err
+}
+
+// WriteQueue saves the chapters of the download queue, the file is removed when the queue is empty
+func WriteQueue(chapters []QueuedChapter) error {
+	if len(chapters) == 0 {
+		if err := os.Remove(QueuePath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(chapters, "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(QueuePath(), data, 0644)
+}
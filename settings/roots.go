@@ -0,0 +1,143 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MainRoot is the name of the library root at LibraryPath, it also keeps the covers and thumbnails of all the roots
+const MainRoot = "main"
+
+// LibraryRoot is a folder of the library, for example a local disk for the ongoing series and a NAS for the finished ones
+type LibraryRoot struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// LibraryRoots returns all the roots of the library, the main one first
+func (cfg Config) LibraryRoots() []LibraryRoot {
+	return append([]LibraryRoot{{Name: MainRoot, Path: cfg.LibraryPath}}, cfg.Roots...)
+}
+
+// findRoot returns the root with the given name, an empty name is the main root
+func (cfg Config) findRoot(name string) (LibraryRoot, bool) {
+	if name == "" {
+		name = MainRoot
+	}
+	for _, root := range cfg.LibraryRoots() {
+		if root.Name == name {
+			return root, true
+		}
+	}
+	return LibraryRoot{}, false
+}
+
+/*
+RootPath returns the folder of a library root. an unknown root, for example removed from the settings
+by hand, is located in the main root, but it is never available.
+*/
+func (cfg Config) RootPath(name string) string {
+	if root, found := cfg.findRoot(name); found {
+		return root.Path
+	}
+	return cfg.LibraryPath
+}
+
+// RootAvailable tells if the folder of a library root can be used, a disk not mounted is offline
+func (cfg Config) RootAvailable(name string) bool {
+	root, found := cfg.findRoot(name)
+	if !found {
+		return false
+	}
+	info, err := os.Stat(root.Path)
+	return err == nil && info.IsDir()
+}
+
+// RootName returns the name of the library root of a manga
+func (manga Manga) RootName() string {
+	if manga.Root == "" {
+		return MainRoot
+	}
+	return manga.Root
+}
+
+/*
+CheckLibraryRoots verifies the roots of a configuration: the names must be unique, and the folders
+absolute and never one inside another, so a series folder belongs to a single root.
+*/
+func CheckLibraryRoots(cfg Config) error {
+	roots := cfg.LibraryRoots()
+	for i, root := range roots {
+		if strings.TrimSpace(root.Name) == "" {
+			return errors.New(fmt.Sprintf("the library root %s has no name", root.Path))
+		}
+		if !filepath.IsAbs(root.Path) {
+			return errors.New(fmt.Sprintf("the folder of the library root %s must be an absolute path", root.Name))
+		}
+		for _, other := range roots[:i] {
+			if other.Name == root.Name {
+				return errors.New(fmt.Sprintf("there are two library roots named %s", root.Name))
+			}
+			a, b := filepath.Clean(root.Path), filepath.Clean(other.Path)
+			if a == b || strings.HasPrefix(a, b+string(filepath.Separator)) || strings.HasPrefix(b, a+string(filepath.Separator)) {
+				return errors.New(fmt.Sprintf("the library roots %s and %s can not share their folders", other.Name, root.Name))
+			}
+		}
+	}
+	return nil
+}
+
+// SetLibraryRoots replaces the roots of the library, the roots still used by a manga can not be removed
+func SetLibraryRoots(cfg Settings, roots []LibraryRoot) (newSettings Settings, err error) {
+	newSettings = cfg
+	newSettings.Config.Roots = roots
+	if err = CheckLibraryRoots(newSettings.Config); err != nil {
+		return cfg, err
+	}
+	for _, manga := range cfg.History.Titles {
+		if _, found := newSettings.Config.findRoot(manga.Root); !found {
+			return cfg, errors.New(fmt.Sprintf("the library root %s is used by %s, move it to another root first", manga.Root, manga.Name))
+		}
+	}
+	WriteSettings(newSettings)
+	return newSettings, nil
+}
+
+/*
+MoveSeries moves the folder of a manga to another library root, and saves the new location. the folder
+is renamed when possible, otherwise its files are copied and progress is called after each of them.
+*/
+func MoveSeries(cfg Settings, manga Manga, root string, progress func(done, total int)) (newSettings Settings, moved Manga, err error) {
+	if root == MainRoot {
+		root = ""
+	}
+	if root == manga.Root {
+		return cfg, manga, errors.New(fmt.Sprintf("%s is already in the library root %s", manga.Name, manga.RootName()))
+	}
+	if !cfg.Config.RootAvailable(manga.Root) {
+		return cfg, manga, errors.New(fmt.Sprintf("the library root %s of %s is offline", manga.RootName(), manga.Name))
+	}
+	moved = manga
+	moved.Root = root
+	if !cfg.Config.RootAvailable(moved.Root) {
+		return cfg, manga, errors.New(fmt.Sprintf("the library root %s is offline", moved.RootName()))
+	}
+	// the folder keeps its name, only its root changes
+	source := SeriesPath(cfg.Config, manga)
+	moved.Path = filepath.Join(cfg.Config.RootPath(moved.Root), filepath.Base(source))
+	if _, e := os.Stat(source); e == nil {
+		if err = moveFolder(source, moved.Path, progress); err != nil {
+			return cfg, manga, err
+		}
+	}
+	newSettings = UpdateHistory(cfg, moved)
+	if _, e := os.Stat(source); e == nil {
+		if e = os.RemoveAll(source); e != nil {
+			err = errors.New(fmt.Sprintf("%s has been copied to %s, but %s could not be removed: %s", manga.Name, moved.Path, source, e))
+		}
+	}
+	return newSettings, moved, err
+}
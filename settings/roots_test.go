@@ -0,0 +1,102 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// rootsLibrary returns a library with a second root named usb, and a manga of the main root
+func rootsLibrary(t *testing.T) (Settings, Manga, string) {
+	t.Helper()
+	cfg := testLibrary(t)
+	usb := filepath.Join(filepath.Dir(cfg.Config.LibraryPath), "usb")
+	if err := os.MkdirAll(usb, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Config.Roots = []LibraryRoot{{Name: "usb", Path: usb}}
+	manga := Manga{Title: "naruto", Name: "Naruto", Chapters: []float64{1}}
+	manga.Path = NewSeriesPath(cfg.Config, manga)
+	cbz := writeFile(t, ChapterArchivePath(cfg.Config, manga, 1))
+	cfg.History.Titles = []Manga{manga}
+	return cfg, manga, cbz
+}
+
+func TestMoveSeries(t *testing.T) {
+	cfg, manga, cbz := rootsLibrary(t)
+
+	moved, m, err := MoveSeries(cfg, manga, "usb", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := filepath.Join(cfg.Config.RootPath("usb"), filepath.Base(manga.Path))
+	if m.Root != "usb" || m.Path != expected || moved.History.Titles[0].Path != expected {
+		t.Errorf("MoveSeries() moved %s to %s in the root %q, expected %s in usb", m.Title, m.Path, m.Root, expected)
+	}
+	if exists(cbz) || !exists(ChapterArchivePath(moved.Config, m, 1)) {
+		t.Error("the archive of the manga is not moved")
+	}
+	if _, _, err = MoveSeries(moved, m, "usb", nil); err == nil {
+		t.Error("MoveSeries() moved the manga in its own root")
+	}
+
+	back, m, err := MoveSeries(moved, m, MainRoot, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Root != "" || m.Path != manga.Path || back.History.Titles[0].Root != "" {
+		t.Errorf("MoveSeries() moved %s back to %s in the root %q, expected %s in the main root", m.Title, m.Path, m.Root, manga.Path)
+	}
+	if !exists(cbz) {
+		t.Error("the archive of the manga is not moved back")
+	}
+}
+
+func TestMoveSeriesOffline(t *testing.T) {
+	cfg, manga, cbz := rootsLibrary(t)
+	if err := os.Remove(cfg.Config.RootPath("usb")); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Config.RootAvailable(MainRoot) || cfg.Config.RootAvailable("usb") || cfg.Config.RootAvailable("unknown") {
+		t.Error("RootAvailable() does not tell the offline and unknown roots")
+	}
+	if _, _, err := MoveSeries(cfg, manga, "usb", nil); err == nil {
+		t.Error("MoveSeries() moved the manga to an offline root")
+	}
+	if !exists(cbz) {
+		t.Error("the archive of the manga is moved")
+	}
+}
+
+func TestMoveSeriesTargetTaken(t *testing.T) {
+	cfg, manga, cbz := rootsLibrary(t)
+	taken := writeFile(t, filepath.Join(cfg.Config.RootPath("usb"), filepath.Base(manga.Path), "other.cbz"))
+	moved, _, err := MoveSeries(cfg, manga, "usb", nil)
+	if err == nil {
+		t.Fatal("MoveSeries() moved the manga in a folder that is not empty")
+	}
+	if !exists(cbz) || !exists(taken) || moved.History.Titles[0].Root != "" {
+		t.Error("a failed move changed the files or the history")
+	}
+}
+
+func TestCheckLibraryRoots(t *testing.T) {
+	library := filepath.FromSlash("/data/mangas")
+	tests := []struct {
+		roots []LibraryRoot
+		ok    bool
+	}{
+		{nil, true},
+		{[]LibraryRoot{{Name: "usb", Path: filepath.FromSlash("/media/usb")}}, true},
+		{[]LibraryRoot{{Name: "", Path: filepath.FromSlash("/media/usb")}}, false},
+		{[]LibraryRoot{{Name: "usb", Path: "usb"}}, false},
+		{[]LibraryRoot{{Name: MainRoot, Path: filepath.FromSlash("/media/usb")}}, false},
+		{[]LibraryRoot{{Name: "usb", Path: filepath.FromSlash("/data/mangas/usb")}}, false},
+		{[]LibraryRoot{{Name: "usb", Path: filepath.FromSlash("/media/usb")}, {Name: "nas", Path: filepath.FromSlash("/media")}}, false},
+	}
+	for _, test := range tests {
+		if err := CheckLibraryRoots(Config{LibraryPath: library, Roots: test.roots}); (err == nil) != test.ok {
+			t.Errorf("CheckLibraryRoots(%v) = %v, expected ok %v", test.roots, err, test.ok)
+		}
+	}
+}
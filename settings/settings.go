@@ -1,16 +1,22 @@
 package settings
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/user"
 	"sort"
+	"sync"
 )
 
 func getSettingsPath() string {
+	if settingsFile != "" {
+		return settingsFile
+	}
 	usr, err := user.Current()
 	if err != nil {
 		log.Fatalf("Error when trying to get current usr: %s\n", err)
@@ -18,6 +24,38 @@ func getSettingsPath() string {
 	return usr.HomeDir + "/.gomangareader.json"
 }
 
+// settingsFile replaces the settings file of the user when it is set, by the tests
+var settingsFile string
+
+// settingsData is the content of the settings file, as last read or written by this process
+var settingsData []byte
+var settingsDataLock sync.Mutex
+
+func rememberSettings(data []byte) {
+	settingsDataLock.Lock()
+	defer settingsDataLock.Unlock()
+	settingsData = data
+}
+
+// SettingsPath returns the path of the settings file
+func SettingsPath() string {
+	return getSettingsPath()
+}
+
+/*
+SettingsModified tells if the settings file was changed by another process, or by hand, since it was
+last read or written by this process.
+*/
+func SettingsModified() bool {
+	data, err := os.ReadFile(getSettingsPath())
+	if err != nil {
+		return false
+	}
+	settingsDataLock.Lock()
+	defer settingsDataLock.Unlock()
+	return !bytes.Equal(data, settingsData)
+}
+
 /*
 IsSettingsExisting allows to check if the settings file already exists or no
 */
@@ -53,6 +91,11 @@ func WriteDefaultSettings() {
 			ThumbnailHeight:      196,
 			ThumbTextHeight:      20,
 			NbWorkers:            4,
+			NbWorkersPerHost:     2,
+			UpdateCheckInterval:  360,
+			ArchiveCompression:   "auto",
+			ArchiveWorkers:       2,
+			TrashRetention:       DefaultTrashRetention,
 		},
 		History{
 			Titles: []Manga{},
@@ -66,6 +109,23 @@ func WriteDefaultSettings() {
 ReadSettings read the settings file
 */
 func ReadSettings() (settings Settings) {
+	settings, err := LoadSettings()
+	var pathError *os.PathError
+	if errors.As(err, &pathError) {
+		log.Fatalf("Error when trying to open settings file: %s\n", err)
+	}
+	if err != nil {
+		// the content is not valid
+		return Settings{}
+	}
+	return settings
+}
+
+/*
+LoadSettings read the settings file, and returns an error instead of stopping the application when the
+file is missing or can not be read, for example while another process replaces it.
+*/
+func LoadSettings() (settings Settings, err error) {
 
 	// Open our jsonFile
 	settingsPath := getSettingsPath()
@@ -73,7 +133,7 @@ func ReadSettings() (settings Settings) {
 	jsonFile, err := os.Open(settingsPath)
 	// if we os.Open returns an error then handle it
 	if err != nil {
-		log.Fatalf("Error when trying to open settings file: %s\n", err)
+		return Settings{}, err
 	}
 
 	//log.Println("Successfully Opened settings.json")
@@ -86,13 +146,29 @@ func ReadSettings() (settings Settings) {
 	}(jsonFile)
 
 	// read our opened jsonFile as a byte array.
-	byteValue, _ := ioutil.ReadAll(jsonFile)
+	byteValue, err := ioutil.ReadAll(jsonFile)
+	if err != nil {
+		return Settings{}, err
+	}
 
 	// we unmarshal our byteArray which contains our
 	// jsonFile's content into 'settings' which we defined above
 	err = json.Unmarshal(byteValue, &settings)
 	if err != nil {
-		return Settings{}
+		return Settings{}, err
+	}
+	rememberSettings(byteValue)
+
+	migrate := resolvePaths(&settings)
+	if migrate {
+		log.Println("The paths of the library are now stored relative to the library folder.")
+	}
+	if pinChapterFiles(&settings) {
+		log.Println("The folders and the archive names of the downloaded chapters are now stored with the history.")
+		migrate = true
+	}
+	if migrate {
+		defer WriteSettings(settings)
 	}
 
 	titles := settings.History.Titles
@@ -101,15 +177,17 @@ func ReadSettings() (settings Settings) {
 	})
 	settings.History.Titles = titles
 
-	return
+	return settings, nil
 }
 
 /*
 WriteSettings write a settings file. used to change the default config or add manga to history download
 */
 func WriteSettings(settings Settings) {
-	file, _ := json.MarshalIndent(settings, "", " ")
+	file, _ := json.MarshalIndent(storedSettings(settings), "", " ")
+	rememberSettings(file)
 	_ = ioutil.WriteFile(getSettingsPath(), file, 0644)
+	WriteSidecars(settings)
 }
 
 /*
@@ -127,27 +205,50 @@ func UpdateHistory(cfg Settings, manga Manga) (newSettings Settings) {
 		return titles[i].Title < titles[j].Title
 	})
 	newSettings = Settings{
-		Config{
-			LibraryPath:          cfg.Config.LibraryPath,
-			AutoUpdate:           cfg.Config.AutoUpdate,
-			NbColumns:            cfg.Config.NbColumns,
-			NbRows:               cfg.Config.NbRows,
-			PageWidth:            cfg.Config.PageWidth,
-			PageHeight:           cfg.Config.PageHeight,
-			ThumbMiniWidth:       cfg.Config.ThumbMiniWidth,
-			ThumbMiniHeight:      cfg.Config.ThumbMiniHeight,
-			LeftRightButtonWidth: cfg.Config.LeftRightButtonWidth,
-			ChapterLabelWidth:    cfg.Config.ChapterLabelWidth,
-			ThumbnailWidth:       cfg.Config.ThumbnailWidth,
-			ThumbnailHeight:      cfg.Config.ThumbnailHeight,
-			ThumbTextHeight:      cfg.Config.ThumbTextHeight,
-			NbWorkers:            cfg.Config.NbWorkers,
-		},
+		cfg.Config,
 		History{
-			Titles: titles,
+			Titles:    titles,
+			Downloads: cfg.History.Downloads,
 		},
 	}
 	WriteSettings(newSettings)
 	//log.Println("History updated.")
 	return
 }
+
+/*
+KeepLocalSettings copy the settings that only exist locally, and are unknown from the provider, from the
+manga stored in the history to the same manga freshly retrieved from the provider
+*/
+func KeepLocalSettings(local Manga, remote Manga) Manga {
+	remote.SkipUpdateCheck = local.SkipUpdateCheck
+	remote.AvailableChapter = local.AvailableChapter
+	remote.LastUpdateCheck = local.LastUpdateCheck
+	remote.AutoDownload = local.AutoDownload
+	remote.ChapterTitles = local.ChapterTitles
+	remote.ChapterVolumes = local.ChapterVolumes
+	remote.PageCounts = local.PageCounts
+	remote.CustomCover = local.CustomCover
+	remote.Root = local.Root
+	remote.ChapterFiles = local.ChapterFiles
+	remote = ApplyOverrides(remote, local.Overrides)
+	if local.Path != "" {
+		// the folder follows the naming profile of the library, not the provider
+		remote.Path = local.Path
+	}
+	return remote
+}
+
+/*
+RecordDownload add a chapter to the downloads log, only the last MaxDownloadRecords downloads are kept
+*/
+func RecordDownload(cfg Settings, record DownloadRecord) (newSettings Settings) {
+	downloads := append(cfg.History.Downloads, record)
+	if len(downloads) > MaxDownloadRecords {
+		downloads = downloads[len(downloads)-MaxDownloadRecords:]
+	}
+	newSettings = cfg
+	newSettings.History.Downloads = downloads
+	WriteSettings(newSettings)
+	return
+}
@@ -0,0 +1,46 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/*
+testLibrary points the settings file to a temporary folder, and returns the settings of an empty library
+in the same folder. the sidecars written by the previous tests are forgotten.
+*/
+func testLibrary(t *testing.T) Settings {
+	t.Helper()
+	dir := t.TempDir()
+	settingsFile = filepath.Join(dir, "settings.json")
+	t.Cleanup(func() {
+		settingsFile = ""
+		sidecarsLock.Lock()
+		sidecarsWritten = map[string]writtenSidecar{}
+		sidecarsLock.Unlock()
+	})
+	libraryPath := filepath.Join(dir, "mangas")
+	if err := os.MkdirAll(libraryPath, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	return Settings{Config: Config{LibraryPath: libraryPath}}
+}
+
+// writeFile writes a small file, creating its folder, and returns its path
+func writeFile(t *testing.T, path string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(filepath.Base(path)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// exists tells if a file or a folder exists
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,302 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SidecarFileName is the name of the metadata file stored in each series folder
+const SidecarFileName = "series.json"
+
+// sidecarVersion is the version of the Mylar series.json format
+const sidecarVersion = "1.0.2"
+
+/*
+Sidecar is the content of the series.json file of a series folder. the metadata follows the format of
+Mylar, also read by Komga and Kavita, and the manga keeps everything gomangareader knows about the
+series, so the history can be rebuilt from the library alone.
+*/
+type Sidecar struct {
+	Version  string      `json:"version"`
+	Metadata MylarSeries `json:"metadata"`
+	Manga    Manga       `json:"gomangareader"`
+}
+
+// MylarSeries describes a series in the Mylar format, the unknown values are null
+type MylarSeries struct {
+	Type                 string  `json:"type"`
+	Publisher            string  `json:"publisher"`
+	Imprint              *string `json:"imprint"`
+	Name                 string  `json:"name"`
+	ComicID              *int    `json:"comicid"`
+	Year                 int     `json:"year"`
+	DescriptionText      string  `json:"description_text"`
+	DescriptionFormatted *string `json:"description_formatted"`
+	Volume               *int    `json:"volume"`
+	BookType             string  `json:"booktype"`
+	AgeRating            *string `json:"age_rating"`
+	ComicImage           string  `json:"ComicImage"`
+	TotalIssues          int     `json:"total_issues"`
+	PublicationRun       string  `json:"publication_run"`
+	Status               string  `json:"status"`
+}
+
+var sidecarYear = regexp.MustCompile(`\d{4}`)
+
+// NewSidecar builds the sidecar of a manga
+func NewSidecar(manga Manga) Sidecar {
+	name := manga.Name
+	if name == "" {
+		name = manga.Title
+	}
+	status := "Continuing"
+	if s := strings.ToLower(manga.Status); strings.Contains(s, "complete") || strings.Contains(s, "ended") {
+		status = "Ended"
+	}
+	series := MylarSeries{
+		Type:            "comicSeries",
+		Publisher:       manga.Provider,
+		Name:            name,
+		DescriptionText: manga.Description,
+		BookType:        "Print",
+		ComicImage:      manga.CoverUrl,
+		TotalIssues:     len(manga.Chapters),
+		Status:          status,
+	}
+	if year := sidecarYear.FindString(manga.YearOfRelease); year != "" {
+		series.Year, _ = strconv.Atoi(year)
+		series.PublicationRun = year
+	}
+	if manga.ContentRating != "" {
+		rating := manga.ContentRating
+		series.AgeRating = &rating
+	}
+	return Sidecar{Version: sidecarVersion, Metadata: series, Manga: manga}
+}
+
+// SidecarPath returns the path of the series.json file of a manga
+func SidecarPath(cfg Config, manga Manga) string {
+	return filepath.Join(SeriesPath(cfg, manga), SidecarFileName)
+}
+
+// sidecarsWritten remembers the last sidecar written for each manga, so the unchanged ones are not written again
+var sidecarsWritten = map[string]writtenSidecar{}
+var sidecarsLock sync.Mutex
+
+type writtenSidecar struct {
+	path string
+	data []byte
+}
+
+/*
+WriteSidecars writes the series.json file of all the mangas of the history that changed since the last
+call. it is called each time the settings are saved, so the library always describes itself.
+*/
+func WriteSidecars(cfg Settings) {
+	sidecarsLock.Lock()
+	defer sidecarsLock.Unlock()
+	for _, manga := range cfg.History.Titles {
+		if !cfg.Config.RootAvailable(manga.Root) {
+			// the sidecar is written when the root is back
+			continue
+		}
+		path := SidecarPath(cfg.Config, manga)
+		data, err := json.MarshalIndent(NewSidecar(manga.relativePaths(cfg.Config)), "", "  ")
+		if err != nil {
+			log.Printf("Error when trying to build the sidecar of %s: %s", manga.Title, err)
+			continue
+		}
+		last, found := sidecarsWritten[manga.Title]
+		if found && last.path == path && bytes.Equal(last.data, data) {
+			continue
+		}
+		if !found {
+			// first time in this session, the file may be up to date already
+			if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+				sidecarsWritten[manga.Title] = writtenSidecar{path: path, data: data}
+				continue
+			}
+		}
+		if err = writeSidecar(path, data); err != nil {
+			log.Printf("Error when trying to write %s: %s", path, err)
+			continue
+		}
+		if found && last.path != path {
+			// the series folder moved, the old sidecar must not describe it anymore
+			_ = os.Remove(last.path)
+		}
+		sidecarsWritten[manga.Title] = writtenSidecar{path: path, data: data}
+	}
+}
+
+// forgetSidecar forgets the last sidecar written for a manga, when its sidecar was removed
+func forgetSidecar(title string) {
+	sidecarsLock.Lock()
+	defer sidecarsLock.Unlock()
+	delete(sidecarsWritten, title)
+}
+
+// writeSidecar writes a sidecar next to its final name first, so a sidecar is never left half written
+func writeSidecar(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), ".series-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(data)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+	}
+	return err
+}
+
+// ReadSidecar reads the series.json file of a series folder
+func ReadSidecar(path string) (Sidecar, error) {
+	var sidecar Sidecar
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sidecar, err
+	}
+	if err = json.Unmarshal(data, &sidecar); err != nil {
+		return sidecar, errors.New(fmt.Sprintf("%s is not a valid sidecar: %s", path, err))
+	}
+	if sidecar.Manga.Title == "" {
+		return sidecar, errors.New(fmt.Sprintf("%s has no gomangareader data", path))
+	}
+	return sidecar, nil
+}
+
+/*
+ReadSidecars reads all the series.json files of the roots of a library. the paths stored in the sidecars
+are replaced by the ones of the library, since the library may have been moved since they were written.
+when two folders describe the same manga, the most recent sidecar is kept. the offline roots are skipped.
+*/
+func ReadSidecars(cfg Config) (titles []Manga, err error) {
+	var failures []string
+	found := map[string]time.Time{}
+	byTitle := map[string]Manga{}
+	for _, root := range cfg.LibraryRoots() {
+		if !cfg.RootAvailable(root.Name) {
+			log.Printf("The library root %s is offline, its sidecars are not read", root.Name)
+			continue
+		}
+		if e := readSidecars(cfg, root, found, byTitle, &failures); e != nil && err == nil {
+			err = e
+		}
+	}
+	for _, manga := range byTitle {
+		titles = append(titles, manga)
+	}
+	sort.Slice(titles, func(i, j int) bool {
+		return titles[i].Title < titles[j].Title
+	})
+	if err == nil && len(failures) > 0 {
+		err = errors.New(fmt.Sprintf("%d sidecars could not be read:\n%s", len(failures), strings.Join(failures, "\n")))
+	}
+	return titles, err
+}
+
+// readSidecars reads the series.json files of a library root, the failures are collected
+func readSidecars(cfg Config, root LibraryRoot, found map[string]time.Time, byTitle map[string]Manga, failures *[]string) error {
+	libraryPath := root.Path
+	return filepath.WalkDir(libraryPath, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			*failures = append(*failures, walkErr.Error())
+			return nil
+		}
+		if d.IsDir() {
+			if path != libraryPath && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != SidecarFileName {
+			return nil
+		}
+		sidecar, e := ReadSidecar(path)
+		if e != nil {
+			*failures = append(*failures, e.Error())
+			return nil
+		}
+		info, e := d.Info()
+		if e != nil {
+			*failures = append(*failures, e.Error())
+			return nil
+		}
+		manga := sidecar.Manga
+		if modTime, ok := found[manga.Title]; ok && modTime.After(info.ModTime()) {
+			return nil
+		}
+		metadataPath := filepath.Join(cfg.LibraryPath, ".metadata")
+		manga.Path = filepath.Dir(path)
+		manga.Root = root.Name
+		if root.Name == MainRoot {
+			manga.Root = ""
+		}
+		manga.CoverPath = filepath.Join(metadataPath, fmt.Sprintf("%s-cover.jpg", manga.Title))
+		if manga.CustomCover != "" {
+			manga.CustomCover = filepath.Join(metadataPath, filepath.Base(manga.CustomCover))
+		}
+		found[manga.Title] = info.ModTime()
+		byTitle[manga.Title] = manga
+		return nil
+	})
+}
+
+/*
+RebuildHistory reads the sidecars of the library and puts the mangas they describe in the history. the
+mangas of the history without a sidecar are kept. it returns the number of mangas read from the sidecars.
+*/
+func RebuildHistory(cfg Settings) (newSettings Settings, nbTitles int, err error) {
+	titles, err := ReadSidecars(cfg.Config)
+	if len(titles) == 0 {
+		return cfg, 0, err
+	}
+	newSettings = MergeSidecars(cfg, titles)
+	WriteSettings(newSettings)
+	return newSettings, len(titles), err
+}
+
+/*
+MergeSidecars puts the mangas read from the sidecars in the history, in place of the mangas with the same
+title. the mangas of the history without a sidecar are kept. the settings are not saved.
+*/
+func MergeSidecars(cfg Settings, titles []Manga) (newSettings Settings) {
+	fromSidecars := map[string]bool{}
+	merged := append([]Manga{}, titles...)
+	for _, manga := range titles {
+		fromSidecars[manga.Title] = true
+	}
+	for _, manga := range cfg.History.Titles {
+		if !fromSidecars[manga.Title] {
+			merged = append(merged, manga)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Title < merged[j].Title
+	})
+	newSettings = cfg
+	newSettings.History.Titles = merged
+	return
+}
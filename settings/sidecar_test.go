@@ -0,0 +1,99 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewSidecar(t *testing.T) {
+	manga := Manga{Title: "naruto", Provider: "mangareader.cc", Status: "Completed", YearOfRelease: "Released in 1999",
+		ContentRating: "Safe", Chapters: []float64{1, 2}}
+	series := NewSidecar(manga).Metadata
+	if series.Name != "naruto" || series.Status != "Ended" || series.Year != 1999 || series.TotalIssues != 2 {
+		t.Errorf("NewSidecar() = %+v, expected the name naruto, the status Ended, the year 1999 and 2 issues", series)
+	}
+	if series.AgeRating == nil || *series.AgeRating != "Safe" {
+		t.Errorf("NewSidecar() has the age rating %v, expected Safe", series.AgeRating)
+	}
+	if series = NewSidecar(Manga{Title: "ongoing", Name: "Ongoing"}).Metadata; series.Status != "Continuing" || series.AgeRating != nil || series.Year != 0 {
+		t.Errorf("NewSidecar() = %+v, expected a continuing series without age rating nor year", series)
+	}
+}
+
+/*
+TestSidecarRoundTrip writes the sidecars of a library, moves the library and rebuilds its history from
+the sidecars alone.
+*/
+func TestSidecarRoundTrip(t *testing.T) {
+	cfg := testLibrary(t)
+	metadataPath := filepath.Join(cfg.Config.LibraryPath, ".metadata")
+	first := Manga{Title: "first", Name: "First", Provider: "mangareader.cc", Genres: []string{"Action"},
+		Chapters: []float64{1, 2}, LastChapter: 2, CoverPath: filepath.Join(metadataPath, "first-cover.jpg"),
+		CustomCover: filepath.Join(metadataPath, "first-custom.png")}
+	first.Path = NewSeriesPath(cfg.Config, first)
+	second := Manga{Title: "second", Name: "Second", Tags: []string{"Ninja"}, LastChapter: 10}
+	second.Path = NewSeriesPath(cfg.Config, second)
+	cfg.History.Titles = []Manga{first, second}
+	WriteSettings(cfg)
+	for _, manga := range cfg.History.Titles {
+		sidecar, err := ReadSidecar(SidecarPath(cfg.Config, manga))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sidecar.Manga.Path != filepath.Base(manga.Path) {
+			t.Errorf("the sidecar of %s has the path %q, expected a path relative to the library", manga.Title, sidecar.Manga.Path)
+		}
+	}
+
+	moved := filepath.Join(filepath.Dir(cfg.Config.LibraryPath), "moved")
+	if err := os.Rename(cfg.Config.LibraryPath, moved); err != nil {
+		t.Fatal(err)
+	}
+	kept := Manga{Title: "kept", Name: "Kept"}
+	rebuilt, nbTitles, err := RebuildHistory(Settings{Config: Config{LibraryPath: moved}, History: History{Titles: []Manga{kept}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nbTitles != 2 || len(rebuilt.History.Titles) != 3 {
+		t.Fatalf("RebuildHistory() read %d titles and has %d titles, expected 2 and 3", nbTitles, len(rebuilt.History.Titles))
+	}
+	expected := RelocateLibrary(cfg, moved).History.Titles
+	for i, title := range []string{"first", "kept", "second"} {
+		if got := rebuilt.History.Titles[i]; got.Title != title {
+			t.Errorf("the title %d of the history is %s, expected %s", i, got.Title, title)
+		}
+	}
+	for _, manga := range []Manga{rebuilt.History.Titles[0], rebuilt.History.Titles[2]} {
+		want := expected[0]
+		if manga.Title == "second" {
+			want = expected[1]
+			// the sidecars always give the cover of the provider
+			want.CoverPath = filepath.Join(moved, ".metadata", "second-cover.jpg")
+		}
+		if !reflect.DeepEqual(manga, want) {
+			t.Errorf("%s is rebuilt as %+v, expected %+v", manga.Title, manga, want)
+		}
+	}
+}
+
+func TestReadSidecarErrors(t *testing.T) {
+	cfg := testLibrary(t)
+	invalid := writeFile(t, filepath.Join(cfg.Config.LibraryPath, "invalid", SidecarFileName))
+	anonymous := filepath.Join(cfg.Config.LibraryPath, "anonymous", SidecarFileName)
+	if err := os.MkdirAll(filepath.Dir(anonymous), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(anonymous, []byte(`{"version": "1.0.2", "metadata": {"name": "Anonymous"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{invalid, anonymous, filepath.Join(cfg.Config.LibraryPath, "missing", SidecarFileName)} {
+		if _, err := ReadSidecar(path); err == nil {
+			t.Errorf("ReadSidecar(%q) read a sidecar", path)
+		}
+	}
+	if titles, err := ReadSidecars(cfg.Config); len(titles) != 0 || err == nil {
+		t.Errorf("ReadSidecars() = %d titles, %v, expected no titles and an error", len(titles), err)
+	}
+}
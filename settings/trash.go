@@ -0,0 +1,250 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TrashFolder is the folder of each library root where the files of the removed mangas are kept
+const TrashFolder = ".trash"
+
+// trashFileName describes a removed manga, in its folder of the trash of the main root
+const trashFileName = "trash.json"
+
+// DefaultTrashRetention is the number of days a removed manga is kept in the trash when the settings do not tell
+const DefaultTrashRetention = 30
+
+/*
+TrashEntry is a manga removed from the library. the entry is in the trash of the main root, with the
+covers and thumbnails of the manga; its series folder is in the trash of its root.
+*/
+type TrashEntry struct {
+	ID    string    `json:"id"`
+	Date  time.Time `json:"date"`
+	Manga Manga     `json:"manga"`
+	// Folder tells if the series folder was moved to the trash, or left in the library
+	Folder bool `json:"folder"`
+	// Metadata are the names of the covers and thumbnails moved to the trash
+	Metadata []string `json:"metadata"`
+}
+
+// TrashRetentionDays returns the number of days the removed mangas are kept in the trash
+func (cfg Config) TrashRetentionDays() int {
+	if cfg.TrashRetention <= 0 {
+		return DefaultTrashRetention
+	}
+	return cfg.TrashRetention
+}
+
+// trashPath returns the folder of a trash entry in a library root
+func trashPath(rootPath, id string) string {
+	return filepath.Join(rootPath, TrashFolder, id)
+}
+
+// RemoveFromHistory removes a manga from the history, and saves it
+func RemoveFromHistory(cfg Settings, title string) (newSettings Settings) {
+	newSettings = cfg
+	newSettings.History.Titles = nil
+	for _, manga := range cfg.History.Titles {
+		if manga.Title != title {
+			newSettings.History.Titles = append(newSettings.History.Titles, manga)
+		}
+	}
+	WriteSettings(newSettings)
+	return
+}
+
+/*
+RemoveSeries removes a manga from the history. when deleteFiles is true, its series folder and the given
+metadata files (covers and thumbnails) are moved to the trash, otherwise only its sidecar is removed so
+the history does not get it back. in both cases the removal can be undone with RestoreSeries until the
+trash is purged.
+*/
+func RemoveSeries(cfg Settings, manga Manga, metadata []string, deleteFiles bool) (newSettings Settings, entry TrashEntry, err error) {
+	if !cfg.Config.RootAvailable(manga.Root) && deleteFiles {
+		return cfg, entry, errors.New(fmt.Sprintf("the library root %s of %s is offline, its files can not be removed", manga.RootName(), manga.Name))
+	}
+	entry = TrashEntry{
+		ID:    fmt.Sprintf("%s-%s", time.Now().Format("20060102-150405"), manga.Title),
+		Date:  time.Now(),
+		Manga: manga.relativePaths(cfg.Config),
+	}
+	entryPath := trashPath(cfg.Config.LibraryPath, entry.ID)
+	// moved are the files moved to the trash so far as old and new path pairs, put back if the removal fails
+	var moved [][2]string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(moved) - 1; i >= 0; i-- {
+			if e := os.Rename(moved[i][1], moved[i][0]); e != nil {
+				// the entry is kept, it holds files of the series
+				log.Printf("Error when trying to move %s back to %s: %s", moved[i][1], moved[i][0], e)
+				return
+			}
+		}
+		_ = os.RemoveAll(entryPath)
+		_ = os.Remove(trashPath(cfg.Config.RootPath(manga.Root), entry.ID))
+	}()
+	folder := SeriesPath(cfg.Config, manga)
+	if deleteFiles {
+		if _, e := os.Stat(folder); e == nil {
+			target := trashPath(cfg.Config.RootPath(manga.Root), entry.ID)
+			if err = os.MkdirAll(target, os.ModePerm); err != nil {
+				return cfg, entry, err
+			}
+			if err = os.Rename(folder, filepath.Join(target, filepath.Base(folder))); err != nil {
+				return cfg, entry, err
+			}
+			moved = append(moved, [2]string{folder, filepath.Join(target, filepath.Base(folder))})
+			entry.Folder = true
+		}
+		// the entry folder is created once the series folder is in the trash
+		if err = os.MkdirAll(entryPath, os.ModePerm); err != nil {
+			return cfg, entry, err
+		}
+		for _, file := range metadata {
+			if _, e := os.Stat(file); e != nil {
+				continue
+			}
+			if e := os.Rename(file, filepath.Join(entryPath, filepath.Base(file))); e != nil {
+				log.Printf("Error when trying to move %s to the trash: %s", file, e)
+				continue
+			}
+			moved = append(moved, [2]string{file, filepath.Join(entryPath, filepath.Base(file))})
+			entry.Metadata = append(entry.Metadata, filepath.Base(file))
+		}
+	} else {
+		if err = os.MkdirAll(entryPath, os.ModePerm); err != nil {
+			return cfg, entry, err
+		}
+		if cfg.Config.RootAvailable(manga.Root) {
+			_ = os.Remove(SidecarPath(cfg.Config, manga))
+		}
+	}
+	data, _ := json.MarshalIndent(entry, "", " ")
+	if err = ioutil.WriteFile(filepath.Join(entryPath, trashFileName), data, 0644); err != nil {
+		return cfg, entry, err
+	}
+	forgetSidecar(manga.Title)
+	newSettings = RemoveFromHistory(cfg, manga.Title)
+	log.Printf("%s removed from the library, trash entry %s", manga.Title, entry.ID)
+	return newSettings, entry, nil
+}
+
+// ReadTrash returns the mangas of the trash, the most recently removed first
+func ReadTrash(cfg Config) (entries []TrashEntry) {
+	folders, err := os.ReadDir(filepath.Join(cfg.LibraryPath, TrashFolder))
+	if err != nil {
+		return nil
+	}
+	for _, folder := range folders {
+		data, err := os.ReadFile(filepath.Join(cfg.LibraryPath, TrashFolder, folder.Name(), trashFileName))
+		if err != nil {
+			continue
+		}
+		var entry TrashEntry
+		if err = json.Unmarshal(data, &entry); err != nil {
+			log.Printf("Error when trying to read the trash entry %s: %s", folder.Name(), err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Date.After(entries[j].Date)
+	})
+	return entries
+}
+
+/*
+RestoreSeries puts a removed manga back in the history, with its files if they were moved to the trash.
+the manga is restored in its library root, or in the main root if its root does not exist anymore.
+*/
+func RestoreSeries(cfg Settings, entry TrashEntry) (newSettings Settings, manga Manga, err error) {
+	for _, m := range cfg.History.Titles {
+		if m.Title == entry.Manga.Title {
+			return cfg, manga, errors.New(fmt.Sprintf("%s is already in the library", m.Name))
+		}
+	}
+	manga = entry.Manga
+	if _, found := cfg.Config.findRoot(manga.Root); !found {
+		manga.Root = ""
+		manga.Path = ""
+	}
+	if !cfg.Config.RootAvailable(manga.Root) {
+		return cfg, manga, errors.New(fmt.Sprintf("the library root %s of %s is offline", manga.RootName(), manga.Name))
+	}
+	manga = manga.absolutePaths(cfg.Config)
+	if manga.Path == "" {
+		manga.Path = UniqueSeriesPath(cfg, manga)
+	}
+	entryPath := trashPath(cfg.Config.LibraryPath, entry.ID)
+	// the folder of the entry in the root of the manga, it is the entry path in the main root
+	rootEntryPath := trashPath(cfg.Config.RootPath(entry.Manga.Root), entry.ID)
+	if entry.Folder {
+		source := filepath.Join(rootEntryPath, filepath.Base(entry.Manga.Path))
+		if _, e := os.Stat(manga.Path); e == nil {
+			return cfg, manga, errors.New(fmt.Sprintf("%s can not be restored, %s already exists", manga.Name, manga.Path))
+		}
+		if err = moveFolder(source, manga.Path, nil); err != nil {
+			return cfg, manga, err
+		}
+	}
+	metadataPath := filepath.Join(cfg.Config.LibraryPath, ".metadata")
+	var failures []string
+	for _, name := range entry.Metadata {
+		if e := os.Rename(filepath.Join(entryPath, name), filepath.Join(metadataPath, name)); e != nil {
+			failures = append(failures, e.Error())
+		}
+	}
+	if len(failures) > 0 {
+		err = errors.New(fmt.Sprintf("%d metadata files could not be restored:\n%s", len(failures), strings.Join(failures, "\n")))
+	}
+	_ = os.RemoveAll(entryPath)
+	if entry.Folder {
+		_ = os.RemoveAll(rootEntryPath)
+	}
+	newSettings = UpdateHistory(cfg, manga)
+	log.Printf("%s restored from the trash entry %s", manga.Title, entry.ID)
+	return newSettings, manga, err
+}
+
+/*
+PurgeTrash deletes the mangas removed for longer than the retention of the trash. the series folders
+of the offline roots are deleted the next time. it returns the number of mangas deleted.
+*/
+func PurgeTrash(cfg Config) (nbPurged int, err error) {
+	var failures []string
+	limit := time.Now().AddDate(0, 0, -cfg.TrashRetentionDays())
+	for _, entry := range ReadTrash(cfg) {
+		if entry.Date.After(limit) {
+			continue
+		}
+		if entry.Folder {
+			if !cfg.RootAvailable(entry.Manga.Root) {
+				continue
+			}
+			if e := os.RemoveAll(trashPath(cfg.RootPath(entry.Manga.Root), entry.ID)); e != nil {
+				failures = append(failures, e.Error())
+				continue
+			}
+		}
+		if e := os.RemoveAll(trashPath(cfg.LibraryPath, entry.ID)); e != nil {
+			failures = append(failures, e.Error())
+			continue
+		}
+		nbPurged++
+	}
+	if len(failures) > 0 {
+		err = errors.New(fmt.Sprintf("%d trash entries could not be deleted:\n%s", len(failures), strings.Join(failures, "\n")))
+	}
+	return nbPurged, err
+}
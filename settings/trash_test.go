@@ -0,0 +1,126 @@
+package settings
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// trashLibrary returns a library with two mangas, the archive of the first one and its cover
+func trashLibrary(t *testing.T) (Settings, Manga, string, string) {
+	t.Helper()
+	cfg := testLibrary(t)
+	manga := Manga{Title: "removed", Name: "Removed", Chapters: []float64{1}}
+	manga.Path = NewSeriesPath(cfg.Config, manga)
+	cbz := writeFile(t, ChapterArchivePath(cfg.Config, manga, 1))
+	cover := writeFile(t, filepath.Join(cfg.Config.LibraryPath, ".metadata", "removed-cover.jpg"))
+	kept := Manga{Title: "kept", Name: "Kept"}
+	kept.Path = NewSeriesPath(cfg.Config, kept)
+	cfg.History.Titles = []Manga{kept, manga}
+	return cfg, manga, cbz, cover
+}
+
+func TestRemoveRestorePurge(t *testing.T) {
+	cfg, manga, cbz, cover := trashLibrary(t)
+
+	removed, entry, err := RemoveSeries(cfg, manga, []string{cover}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed.History.Titles) != 1 || removed.History.Titles[0].Title != "kept" {
+		t.Errorf("the history has %d titles after the removal, expected only kept", len(removed.History.Titles))
+	}
+	if exists(cbz) || exists(cover) {
+		t.Error("the files of the manga are still in the library")
+	}
+	entries := ReadTrash(removed.Config)
+	if len(entries) != 1 || entries[0].ID != entry.ID || !entries[0].Folder || len(entries[0].Metadata) != 1 {
+		t.Fatalf("the trash has the entries %+v, expected %+v", entries, entry)
+	}
+
+	restored, m, err := RestoreSeries(removed, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := findTitle(restored, manga.Title); !found || m.Path != manga.Path {
+		t.Errorf("%s is not restored in %s", manga.Title, manga.Path)
+	}
+	if !exists(cbz) || !exists(cover) {
+		t.Error("the files of the manga are not restored")
+	}
+	if entries := ReadTrash(restored.Config); len(entries) != 0 {
+		t.Errorf("the trash still has %d entries after the restore", len(entries))
+	}
+
+	removed, entry, err = RemoveSeries(restored, m, []string{cover}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nbPurged, err := PurgeTrash(removed.Config); err != nil || nbPurged != 0 {
+		t.Errorf("PurgeTrash deleted %d entries before the end of the retention: %v", nbPurged, err)
+	}
+	// the entry is older than the retention now
+	entry.Date = time.Now().AddDate(0, 0, -removed.Config.TrashRetentionDays()-1)
+	data, _ := json.Marshal(entry)
+	if err = os.WriteFile(filepath.Join(trashPath(removed.Config.LibraryPath, entry.ID), trashFileName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if nbPurged, err := PurgeTrash(removed.Config); err != nil || nbPurged != 1 {
+		t.Errorf("PurgeTrash deleted %d entries, expected 1: %v", nbPurged, err)
+	}
+	if exists(trashPath(removed.Config.LibraryPath, entry.ID)) || exists(manga.Path) {
+		t.Error("the files of the purged manga are still there")
+	}
+}
+
+func TestRemoveKeepingFiles(t *testing.T) {
+	cfg, manga, cbz, cover := trashLibrary(t)
+	WriteSettings(cfg)
+	sidecar := SidecarPath(cfg.Config, manga)
+	if !exists(sidecar) {
+		t.Fatalf("%s was not written", sidecar)
+	}
+
+	removed, entry, err := RemoveSeries(cfg, manga, []string{cover}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists(cbz) || !exists(cover) || exists(sidecar) {
+		t.Error("the files of the manga are moved, or its sidecar is kept")
+	}
+	if _, _, err = RestoreSeries(removed, entry); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(cbz) {
+		t.Error("the archive of the manga is lost")
+	}
+}
+
+func TestRemoveFailure(t *testing.T) {
+	cfg, manga, cbz, cover := trashLibrary(t)
+	// the trash can not be created, a file has its name
+	writeFile(t, filepath.Join(cfg.Config.LibraryPath, TrashFolder))
+
+	removed, _, err := RemoveSeries(cfg, manga, []string{cover}, true)
+	if err == nil {
+		t.Fatal("the manga was removed without a trash")
+	}
+	if len(removed.History.Titles) != 2 {
+		t.Errorf("the history has %d titles, expected 2", len(removed.History.Titles))
+	}
+	if !exists(cbz) || !exists(cover) {
+		t.Error("the files of the manga were moved")
+	}
+}
+
+// findTitle returns the manga of the history with the given title
+func findTitle(cfg Settings, title string) (Manga, bool) {
+	for _, manga := range cfg.History.Titles {
+		if manga.Title == title {
+			return manga, true
+		}
+	}
+	return Manga{}, false
+}
@@ -1,117 +1,71 @@
 package widget
 
 import (
-	"archive/zip"
-	"fmt"
-	"io"
-	"log"
+	"github.com/francoiscolombo/gomangareader/archive"
+	"github.com/francoiscolombo/gomangareader/settings"
 	"os"
 	"path/filepath"
 )
 
-func createCBZ(outputPath, pagesPath, title string, chapter float64) error {
+// chapterArchive returns the path of the cbz archive of a chapter, following the naming profile
+func chapterArchive(manga settings.Manga, chapter float64) string {
+	return settings.ChapterArchivePath(currentConfig().Config, manga, chapter)
+}
+
+/*
+createCBZ archives the pages of a chapter with its ComicInfo.xml metadata, then removes the pages folder
+*/
+func createCBZ(outputCBZ, pagesPath string, manga settings.Manga, chapter float64) error {
 	// create output path
-	err := os.MkdirAll(outputPath, os.ModePerm)
+	err := os.MkdirAll(filepath.Dir(outputCBZ), os.ModePerm)
 	if err != nil {
 		return err
 	}
 	// List of Files to Zip
 	var files []string
-	outputCBZ := filepath.FromSlash(fmt.Sprintf("%s/%s-%03.1f.cbz", outputPath, title, chapter))
-	//fmt.Printf("\ncreate %s ... ", outputCBZ)
 	err = filepath.Walk(pagesPath, func(path string, info os.FileInfo, err error) error {
-		src, err := os.Stat(path)
 		if err != nil {
-			// still does not exists? then something wrong, exit in panic mode.
-			panic(err)
+			return err
 		}
-		if !src.IsDir() {
+		if !info.IsDir() {
 			files = append(files, path)
 		}
 		return nil
 	})
-	if err != nil {
-		panic(err)
-	}
-
-	// create archive
-	newZipFile, err := os.Create(outputCBZ)
 	if err != nil {
 		return err
 	}
-	defer func(newZipFile *os.File) {
-		err := newZipFile.Close()
-		if err != nil {
-			log.Printf("Something went wrong while trying to close the zip file, error is %s", err)
-		}
-	}(newZipFile)
+	archive.SortNatural(files)
 
-	zipWriter := zip.NewWriter(newZipFile)
-	defer func(zipWriter *zip.Writer) {
-		err := zipWriter.Close()
-		if err != nil {
-			log.Printf("Something went wrong while trying to close the zip file, error is %s", err)
-		}
-	}(zipWriter)
-
-	// Add files to zip
-	for _, file := range files {
-		if err = addFileToZip(zipWriter, file); err != nil {
-			return err
-		} else {
-			err := os.Remove(file)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	// remove temporary folder
-	err = os.Remove(pagesPath)
+	// the metadata goes after the pages, so the first entry is still the first page
+	info := newComicInfo(manga, chapter, pagesInfoFromFiles(files))
+	err = archive.Create(outputCBZ, files, &info, archive.CreateOptions{
+		Compression: archive.ParseCompression(currentConfig().Config.ArchiveCompression),
+		Workers:     currentConfig().Config.ArchiveWorkers,
+	})
 	if err != nil {
 		return err
 	}
-	//fmt.Println("done")
 
-	return nil
+	// remove temporary folder
+	return os.RemoveAll(pagesPath)
 }
 
-func addFileToZip(zipWriter *zip.Writer, filename string) error {
-
-	fileToZip, err := os.Open(filename)
-	if err != nil {
-		return err
+// applyArchiveLimits gives the limits of the configuration to the archive package, missing ones keep their default
+func applyArchiveLimits() {
+	l := archive.DefaultLimits
+	cfg := currentConfig().Config.ArchiveLimits
+	if cfg.MaxSizeMB > 0 {
+		l.MaxTotalSize = int64(cfg.MaxSizeMB) << 20
 	}
-	defer func(fileToZip *os.File) {
-		err := fileToZip.Close()
-		if err != nil {
-			log.Printf("Something went wrong while trying to add file to the zip file, error is %s", err)
-		}
-	}(fileToZip)
-
-	// Get the file information
-	info, err := fileToZip.Stat()
-	if err != nil {
-		return err
+	if cfg.MaxEntries > 0 {
+		l.MaxEntries = cfg.MaxEntries
 	}
-
-	header, err := zip.FileInfoHeader(info)
-	if err != nil {
-		return err
+	if cfg.MaxCompressionRatio > 0 {
+		l.MaxCompressionRatio = cfg.MaxCompressionRatio
 	}
-
-	// Using FileInfoHeader() above only uses the basename of the file. If we want
-	// to preserve the folder structure we can overwrite this with the full path.
-	//header.Name = filename
-
-	// Change to deflate to gain better compression
-	// see http://golang.org/pkg/archive/zip/#pkg-constants
-	header.Method = zip.Deflate
-
-	writer, err := zipWriter.CreateHeader(header)
-	if err != nil {
-		return err
+	if cfg.MaxPageDimension > 0 {
+		l.MaxImageDimension = cfg.MaxPageDimension
 	}
-	_, err = io.Copy(writer, fileToZip)
-	return err
+	archive.SetLimits(l)
 }
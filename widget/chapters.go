@@ -53,7 +53,7 @@ func (c *Chapters) CreateRenderer() fyne.WidgetRenderer {
 	bg := canvas.NewRectangle(theme.ButtonColor())
 
 	thumbnail := &canvas.Image{FillMode: canvas.ImageFillOriginal}
-	thumbnail.File = filepath.FromSlash(fmt.Sprintf("%s/%s-%s.jpg", c.ThumbnailPath, c.Title, c.Chapters[c.CurrentChapterIndex]))
+	thumbnail.File = settings.ThumbnailPath(currentConfig().Config, *c.Manga, c.Manga.Chapters[c.CurrentChapterIndex])
 
 	chapter := canvas.NewText(fmt.Sprintf("%s - Chapter %s", c.Title, c.Chapters[c.CurrentChapterIndex]), theme.ForegroundColor())
 	chapter.TextSize = 12
@@ -77,6 +77,9 @@ func (c *Chapters) CreateRenderer() fyne.WidgetRenderer {
 	})
 
 	readThis := widget.NewButtonWithIcon("Read this chapter...", theme.DocumentIcon(), func() {
+		if reader != nil {
+			reader.Close()
+		}
 		reader = NewReader(c.Manga, c.Manga.Chapters[c.CurrentChapterIndex])
 		reader.Refresh()
 		application.Preferences().SetInt(c.Manga.Title, c.CurrentChapterIndex)
@@ -123,7 +126,7 @@ func (c *ChaptersRenderer) Destroy() {
 }
 
 func (c *ChaptersRenderer) MinSize() fyne.Size {
-	return fyne.NewSize(config.Config.ThumbMiniWidth+config.Config.LeftRightButtonWidth*2+config.Config.ChapterLabelWidth+theme.Padding()*7+200, config.Config.ThumbMiniHeight+theme.Padding()*2)
+	return fyne.NewSize(currentConfig().Config.ThumbMiniWidth+currentConfig().Config.LeftRightButtonWidth*2+currentConfig().Config.ChapterLabelWidth+theme.Padding()*7+200, currentConfig().Config.ThumbMiniHeight+theme.Padding()*2)
 }
 
 func (c *ChaptersRenderer) Layout(_ fyne.Size) {
@@ -131,25 +134,25 @@ func (c *ChaptersRenderer) Layout(_ fyne.Size) {
 	dx := p
 	dy := p
 
-	c.previous.Resize(fyne.NewSize(config.Config.LeftRightButtonWidth, config.Config.ThumbMiniHeight))
+	c.previous.Resize(fyne.NewSize(currentConfig().Config.LeftRightButtonWidth, currentConfig().Config.ThumbMiniHeight))
 	c.previous.Move(fyne.NewPos(dx, dy))
-	dx = dx + config.Config.LeftRightButtonWidth + p
+	dx = dx + currentConfig().Config.LeftRightButtonWidth + p
 
-	c.thumbnail.Resize(fyne.NewSize(config.Config.ThumbMiniWidth, config.Config.ThumbMiniHeight))
+	c.thumbnail.Resize(fyne.NewSize(currentConfig().Config.ThumbMiniWidth, currentConfig().Config.ThumbMiniHeight))
 	c.thumbnail.Move(fyne.NewPos(dx, dy))
-	dx = dx + config.Config.ThumbMiniWidth + p
+	dx = dx + currentConfig().Config.ThumbMiniWidth + p
 
-	c.next.Resize(fyne.NewSize(config.Config.LeftRightButtonWidth, config.Config.ThumbMiniHeight))
+	c.next.Resize(fyne.NewSize(currentConfig().Config.LeftRightButtonWidth, currentConfig().Config.ThumbMiniHeight))
 	c.next.Move(fyne.NewPos(dx, dy))
-	dx = dx + config.Config.LeftRightButtonWidth + p
+	dx = dx + currentConfig().Config.LeftRightButtonWidth + p
 
-	c.chapter.Resize(fyne.NewSize(config.Config.ChapterLabelWidth, config.Config.ThumbMiniHeight))
+	c.chapter.Resize(fyne.NewSize(currentConfig().Config.ChapterLabelWidth, currentConfig().Config.ThumbMiniHeight))
 	c.chapter.Move(fyne.NewPos(dx, dy))
-	dx = dx + config.Config.ChapterLabelWidth + p
+	dx = dx + currentConfig().Config.ChapterLabelWidth + p
 
-	c.readThis.Resize(fyne.NewSize(200, config.Config.ThumbMiniHeight/2-p))
+	c.readThis.Resize(fyne.NewSize(200, currentConfig().Config.ThumbMiniHeight/2-p))
 	c.readThis.Move(fyne.NewPos(dx, dy))
-	dy = dy + config.Config.ThumbMiniHeight/2 + p
+	dy = dy + currentConfig().Config.ThumbMiniHeight/2 + p
 
 }
 
@@ -166,7 +169,7 @@ func (c *ChaptersRenderer) Objects() []fyne.CanvasObject {
 func (c *ChaptersRenderer) Refresh() {
 
 	c.thumbnail = &canvas.Image{FillMode: canvas.ImageFillOriginal}
-	c.thumbnail.File = filepath.FromSlash(fmt.Sprintf("%s/%s-%s.jpg", c.chapters.ThumbnailPath, c.chapters.Title, c.chapters.Chapters[c.chapters.CurrentChapterIndex]))
+	c.thumbnail.File = settings.ThumbnailPath(currentConfig().Config, *c.chapters.Manga, c.chapters.Manga.Chapters[c.chapters.CurrentChapterIndex])
 	c.thumbnail.Refresh()
 
 	c.chapter = canvas.NewText(fmt.Sprintf("%s - Chapter %s", c.chapters.Title, c.chapters.Chapters[c.chapters.CurrentChapterIndex]), theme.ForegroundColor())
@@ -0,0 +1,202 @@
+package widget
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/francoiscolombo/gomangareader/archive"
+	"github.com/francoiscolombo/gomangareader/settings"
+	"image"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var yearPattern = regexp.MustCompile(`\d{4}`)
+
+/*
+newComicInfo build the metadata of a chapter from the details of the manga. the pages are described
+by the caller, since they come from the download folder or from an existing archive.
+*/
+func newComicInfo(manga settings.Manga, chapter float64, pages []archive.ComicPageInfo) archive.ComicInfo {
+	key := settings.ChapterKey(chapter)
+	series := manga.Name
+	if series == "" {
+		series = manga.Title
+	}
+	info := archive.ComicInfo{
+		Title:       manga.ChapterTitles[key],
+		Series:      series,
+		Number:      key,
+		Volume:      manga.ChapterVolumes[key],
+		Summary:     manga.Description,
+		Writer:      manga.Author,
+		Penciller:   manga.Artist,
+		Genre:       manga.Detail("genres"),
+		Tags:        manga.Detail("tags"),
+		AgeRating:   manga.ContentRating,
+		PageCount:   len(pages),
+		LanguageISO: "en",
+		Manga:       "YesAndRightToLeft",
+		Pages:       pages,
+	}
+	if manga.Status != "" {
+		info.Notes = fmt.Sprintf("Status: %s", manga.Status)
+	}
+	if manga.Provider != "" {
+		info.Web = fmt.Sprintf("https://%s", manga.Provider)
+	}
+	if year := yearPattern.FindString(manga.YearOfRelease); year != "" {
+		info.Year, _ = strconv.Atoi(year)
+	}
+	return info
+}
+
+/*
+newComicPageInfo describes a page, its dimensions are read from the image header. the first page is
+flagged as the cover, and pages wider than high are flagged as double pages.
+*/
+func newComicPageInfo(index int, data io.Reader, size int64) archive.ComicPageInfo {
+	page := archive.ComicPageInfo{Image: index, ImageSize: size}
+	if index == 0 {
+		page.Type = "FrontCover"
+	}
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return page
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(content)); err == nil {
+		page.ImageWidth, page.ImageHeight = cfg.Width, cfg.Height
+	}
+	page.DoublePage = page.ImageWidth > page.ImageHeight
+	return page
+}
+
+// pagesInfoFromFiles describes the pages of a chapter before they are archived
+func pagesInfoFromFiles(files []string) []archive.ComicPageInfo {
+	var pages []archive.ComicPageInfo
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		info, err := f.Stat()
+		if err == nil {
+			pages = append(pages, newComicPageInfo(len(pages), f, info.Size()))
+		}
+		_ = f.Close()
+	}
+	return pages
+}
+
+// pagesInfoFromArchive describes the pages of an existing chapter archive
+func pagesInfoFromArchive(cbzArchive string) ([]archive.ComicPageInfo, error) {
+	r, err := archive.Open(cbzArchive)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var pages []archive.ComicPageInfo
+	for i := range r.Pages() {
+		data, err := r.ReadPage(i)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, newComicPageInfo(i, bytes.NewReader(data), int64(len(data))))
+	}
+	return pages, nil
+}
+
+/*
+BackfillComicInfo adds a ComicInfo.xml to all the chapters of the library that were downloaded before
+the archives had one. when force is true, the metadata of all the archives is written again.
+it returns the number of archives updated.
+*/
+func BackfillComicInfo(force bool) (int, error) {
+	loadConfig()
+	nbUpdated := 0
+	var failures []string
+	for _, manga := range currentConfig().History.Titles {
+		for _, chapter := range manga.Chapters {
+			cbzArchive := chapterArchive(manga, chapter)
+			if _, err := os.Stat(cbzArchive); err != nil {
+				continue
+			}
+			if !force {
+				if found, err := archive.HasComicInfo(cbzArchive); err != nil || found {
+					continue
+				}
+			}
+			pages, err := pagesInfoFromArchive(cbzArchive)
+			if err == nil {
+				err = archive.AddComicInfo(cbzArchive, newComicInfo(manga, chapter, pages))
+			}
+			if err != nil {
+				log.Printf("Error when trying to add ComicInfo.xml to %s: %s", cbzArchive, err)
+				failures = append(failures, cbzArchive)
+				continue
+			}
+			nbUpdated++
+		}
+	}
+	if len(failures) > 0 {
+		return nbUpdated, errors.New(fmt.Sprintf("ComicInfo.xml could not be added to %d archives:\n%s", len(failures), strings.Join(failures, "\n")))
+	}
+	return nbUpdated, nil
+}
+
+/*
+applyMetadata copies the metadata of a chapter archive to the manga: the chapter title and volume, and
+the details of the series that the provider did not give. it returns true if the manga changed.
+*/
+func applyMetadata(manga *settings.Manga, chapter float64, meta archive.Metadata) bool {
+	changed := false
+	setString := func(field *string, value string) {
+		if *field == "" && value != "" {
+			*field = value
+			changed = true
+		}
+	}
+	setString(&manga.Name, meta.Series)
+	setString(&manga.Author, meta.Writer)
+	setString(&manga.Artist, meta.Penciller)
+	setString(&manga.Description, meta.Summary)
+	if meta.Year > 0 {
+		setString(&manga.YearOfRelease, strconv.Itoa(meta.Year))
+	}
+	setString(&manga.ContentRating, meta.AgeRating)
+	if len(manga.Genres) == 0 && meta.Genre != "" {
+		manga.Genres = settings.ParseList(meta.Genre)
+		changed = true
+	}
+	if len(manga.Tags) == 0 && meta.Tags != "" {
+		manga.Tags = settings.ParseList(meta.Tags)
+		changed = true
+	}
+	// the maps are copied, the history shares them with the previous settings
+	key := settings.ChapterKey(chapter)
+	if meta.Title != "" && manga.ChapterTitles[key] != meta.Title {
+		titles := map[string]string{key: meta.Title}
+		for k, v := range manga.ChapterTitles {
+			if k != key {
+				titles[k] = v
+			}
+		}
+		manga.ChapterTitles = titles
+		changed = true
+	}
+	if meta.Volume > 0 && manga.ChapterVolumes[key] != meta.Volume {
+		volumes := map[string]int{key: meta.Volume}
+		for k, v := range manga.ChapterVolumes {
+			if k != key {
+				volumes[k] = v
+			}
+		}
+		manga.ChapterVolumes = volumes
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,156 @@
+package widget
+
+import (
+	"github.com/francoiscolombo/gomangareader/settings"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+config holds a *settings.Settings, the configuration and the history. the settings are never changed in
+place: each change stores a new copy, so the interface and the background tasks read them through
+currentConfig without ever seeing a change half done.
+*/
+var config atomic.Value
+
+/*
+configLock serializes the changes of the configuration and the history. they are made by the interface,
+and by the background tasks: the update checker, the download queue and the library watcher.
+*/
+var configLock sync.Mutex
+
+// setConfig replaces the settings, when they are read from the settings file
+func setConfig(cfg settings.Settings) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	config.Store(&cfg)
+}
+
+// configLoaded returns true when the settings were read
+func configLoaded() bool {
+	return config.Load() != nil
+}
+
+// loadConfig reads the settings file, unless the settings were already read by the interface
+func loadConfig() {
+	if configLoaded() {
+		return
+	}
+	setConfig(settings.ReadSettings())
+	applyArchiveLimits()
+}
+
+// currentConfig returns the current settings. it never waits for a change in progress.
+func currentConfig() settings.Settings {
+	cfg, _ := config.Load().(*settings.Settings)
+	if cfg == nil {
+		return settings.Settings{}
+	}
+	return *cfg
+}
+
+/*
+updateConfig applies a change to the current settings while holding configLock, so the changes made at
+the same time by the interface and the background tasks are never lost. change must be quick and must not
+take the lock again: the files are moved before, and change only records the result in the settings.
+*/
+func updateConfig(change func(cfg settings.Settings) settings.Settings) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	newSettings := change(currentConfig())
+	config.Store(&newSettings)
+}
+
+/*
+addManga adds a manga to the history, unless a manga with the same title is already there. it returns
+false when the manga was already in the history: nothing is saved then.
+*/
+func addManga(manga settings.Manga) bool {
+	configLock.Lock()
+	defer configLock.Unlock()
+	cfg := currentConfig()
+	if _, found := findMangaIn(cfg, manga.Title); found {
+		return false
+	}
+	newSettings := settings.UpdateHistory(cfg, manga)
+	config.Store(&newSettings)
+	return true
+}
+
+/*
+updateManga applies a change to the manga of the history with the given title. the manga is read again
+under the lock, so the changes made since the caller read it are kept. it returns the manga saved, and
+false if the manga is not in the history anymore: nothing is saved then.
+*/
+func updateManga(title string, change func(manga *settings.Manga)) (settings.Manga, bool) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	cfg := currentConfig()
+	manga, found := findMangaIn(cfg, title)
+	if !found {
+		return manga, false
+	}
+	change(&manga)
+	newSettings := settings.UpdateHistory(cfg, manga)
+	config.Store(&newSettings)
+	return manga, true
+}
+
+/*
+saveRefreshedMangas stores the mangas refreshed from the provider in the history. each one is merged with
+the manga currently in the history, so the local settings and the chapters downloaded during the refresh
+are kept, and a manga removed during the refresh is not added again.
+*/
+func saveRefreshedMangas(refreshed []settings.Manga) {
+	byTitle := make(map[string]settings.Manga, len(refreshed))
+	for _, manga := range refreshed {
+		byTitle[manga.Title] = manga
+	}
+	updateConfig(func(cfg settings.Settings) settings.Settings {
+		titles := make([]settings.Manga, len(cfg.History.Titles))
+		for i, current := range cfg.History.Titles {
+			titles[i] = current
+			if manga, found := byTitle[current.Title]; found {
+				titles[i] = settings.KeepLocalSettings(current, manga)
+				titles[i].LastChapter = current.LastChapter
+			}
+		}
+		cfg.History.Titles = titles
+		settings.WriteSettings(cfg)
+		return cfg
+	})
+}
+
+/*
+applyLayout saves the naming profile of a renamed library over the current settings, with the folder and
+the archive names of its mangas. the archives recorded since the rename started are kept.
+*/
+func applyLayout(cfg settings.Settings, renamed settings.Settings) settings.Settings {
+	cfg.Config.NamingProfile = renamed.Config.NamingProfile
+	cfg.Config.FolderTemplate = renamed.Config.FolderTemplate
+	cfg.Config.FileTemplate = renamed.Config.FileTemplate
+	titles := make([]settings.Manga, len(cfg.History.Titles))
+	for i, manga := range cfg.History.Titles {
+		if r, found := findMangaIn(renamed, manga.Title); found {
+			chapterFiles := map[string]string{}
+			for k, v := range manga.ChapterFiles {
+				chapterFiles[k] = v
+			}
+			for k, v := range r.ChapterFiles {
+				chapterFiles[k] = v
+			}
+			manga.Path, manga.ChapterFiles = r.Path, chapterFiles
+		}
+		titles[i] = manga
+	}
+	cfg.History.Titles = titles
+	settings.WriteSettings(cfg)
+	return cfg
+}
+
+// recordDownload adds a chapter to the downloads log
+func recordDownload(record settings.DownloadRecord) {
+	updateConfig(func(cfg settings.Settings) settings.Settings {
+		return settings.RecordDownload(cfg, record)
+	})
+}
@@ -0,0 +1,318 @@
+package widget
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+	"github.com/francoiscolombo/gomangareader/archive"
+	"github.com/francoiscolombo/gomangareader/settings"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// fallbackCoverPath returns the path of the cover used when the provider has none
+func fallbackCoverPath(manga settings.Manga) string {
+	return filepath.Join(filepath.Dir(manga.CoverPath), fmt.Sprintf("%s-cover-fallback.jpg", manga.Title))
+}
+
+/*
+coverFile returns the cover to display for a manga: the custom cover chosen by the user, or the cover
+of the provider, or the fallback cover.
+*/
+func coverFile(manga settings.Manga) string {
+	for _, cover := range []string{manga.CustomCover, manga.CoverPath, fallbackCoverPath(manga)} {
+		if cover == "" {
+			continue
+		}
+		if _, err := os.Stat(cover); err == nil {
+			return cover
+		}
+	}
+	return manga.CoverPath
+}
+
+/*
+ensureCover makes sure that a manga has a cover, so it can always be displayed in the library. the
+cover of the provider is downloaded first, then the first page of the first chapter downloaded is used,
+and as a last resort a placeholder is generated with the name of the manga.
+*/
+func ensureCover(manga settings.Manga) error {
+	if manga.CustomCover != "" {
+		if _, err := os.Stat(manga.CustomCover); err == nil {
+			return makeCoverThumbnail(manga)
+		}
+	}
+	err := errors.New("the provider has no cover")
+	if manga.CoverUrl != "" {
+		err = downloadCover(manga)
+	}
+	if err == nil {
+		return makeCoverThumbnail(manga)
+	}
+	log.Printf("Unable to get the cover of %s from the provider, a fallback is used: %s", manga.Title, err)
+	if fallbackCoverStale(manga) {
+		err = firstPageCover(manga)
+		if err != nil {
+			err = placeholderCover(manga)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return makeCoverThumbnail(manga)
+}
+
+/*
+fallbackCoverStale tells if the fallback cover must be generated again: it does not exist, or the first
+chapter downloaded is newer than it, so a placeholder is replaced by the first page once a chapter is there.
+*/
+func fallbackCoverStale(manga settings.Manga) bool {
+	fallback := fallbackCoverPath(manga)
+	for _, chapter := range manga.Chapters {
+		if _, err := os.Stat(chapterArchive(manga, chapter)); err == nil {
+			return thumbnailStale(fallback, chapterArchive(manga, chapter))
+		}
+	}
+	_, err := os.Stat(fallback)
+	return err != nil
+}
+
+// firstPageCover writes the first page of the first chapter downloaded as the fallback cover
+func firstPageCover(manga settings.Manga) error {
+	for _, chapter := range manga.Chapters {
+		data, err := readChapterPage(manga, chapter, 0)
+		if err != nil {
+			continue
+		}
+		return writeThumbnail(fallbackCoverPath(manga), data, currentConfig().Config.ThumbnailWidth*2, currentConfig().Config.ThumbnailHeight*2)
+	}
+	return errors.New(fmt.Sprintf("no chapter of %s is downloaded", manga.Title))
+}
+
+// placeholderCover generates a plain cover with the name of the manga as the fallback cover
+func placeholderCover(manga settings.Manga) error {
+	width, height := int(currentConfig().Config.ThumbnailWidth*thumbnailScale), int(currentConfig().Config.ThumbnailHeight*thumbnailScale)
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.NRGBA{R: 0x20, G: 0x30, B: 0x40, A: 0xff}}, image.Point{}, draw.Src)
+
+	name := manga.Name
+	if name == "" {
+		name = manga.Title
+	}
+	face := basicfont.Face7x13
+	drawer := &font.Drawer{Dst: img, Src: image.White, Face: face}
+	// wrap the name on the words, as many characters as the cover width allows
+	maxChars := (width - 16) / 7
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(name) {
+		if line != "" && len(line)+1+len(word) > maxChars {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	lineHeight := face.Metrics().Height.Ceil() + 4
+	y := (height-len(lines)*lineHeight)/2 + face.Metrics().Ascent.Ceil()
+	for _, l := range lines {
+		textWidth := drawer.MeasureString(l).Ceil()
+		drawer.Dot = fixed.P((width-textWidth)/2, y)
+		drawer.DrawString(l)
+		y += lineHeight
+	}
+
+	err := os.MkdirAll(filepath.Dir(fallbackCoverPath(manga)), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(fallbackCoverPath(manga))
+	if err != nil {
+		return err
+	}
+	err = jpeg.Encode(file, img, &jpeg.Options{Quality: thumbnailQuality})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// readChapterPage reads a page of a downloaded chapter, index starts at 0
+func readChapterPage(manga settings.Manga, chapter float64, index int) ([]byte, error) {
+	r, err := archive.Open(chapterArchive(manga, chapter))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return r.ReadPage(index)
+}
+
+/*
+setCustomCover stores an image as the cover chosen by the user for a manga. it is kept next to the
+other covers, and is never replaced by the refreshes of the provider.
+*/
+func setCustomCover(manga *settings.Manga, data []byte) error {
+	format, err := detectImageFormat(data, "")
+	if err != nil {
+		return err
+	}
+	if err = validateImage(data, format); err != nil {
+		return err
+	}
+	if format == "avif" {
+		return errors.New("AVIF covers are not supported, they can not be displayed")
+	}
+	cover := filepath.Join(filepath.Dir(manga.CoverPath), fmt.Sprintf("%s-custom-cover.%s", manga.Title, format))
+	if manga.CustomCover != "" && manga.CustomCover != cover {
+		_ = os.Remove(manga.CustomCover)
+	}
+	if err = os.WriteFile(cover, data, 0644); err != nil {
+		return err
+	}
+	manga.CustomCover = cover
+	coverChanged(manga)
+	return nil
+}
+
+// resetCustomCover goes back to the cover of the provider
+func resetCustomCover(manga *settings.Manga) {
+	if manga.CustomCover != "" {
+		_ = os.Remove(manga.CustomCover)
+	}
+	manga.CustomCover = ""
+	coverChanged(manga)
+}
+
+// coverChanged saves the new cover of a manga, and refreshes the widgets displaying it
+func coverChanged(manga *settings.Manga) {
+	_ = os.Remove(coverThumbnailPath(*manga))
+	err := ensureCover(*manga)
+	if err != nil {
+		log.Printf("Error when trying to update the cover of %s: %s", manga.Title, err)
+	}
+	updateManga(manga.Title, func(m *settings.Manga) {
+		m.CustomCover = manga.CustomCover
+	})
+	if library != nil {
+		for _, tb := range library.Items {
+			if tb.Title.Title == manga.Title {
+				tb.Title.CustomCover = manga.CustomCover
+				tb.Refresh()
+			}
+		}
+	}
+	if series != nil {
+		series.Refresh()
+	}
+}
+
+/*
+showCoverDialog lets the user choose the cover of a manga: any page of a downloaded chapter, or a local
+image file. the custom cover can also be removed to go back to the cover of the provider.
+*/
+func showCoverDialog(manga *settings.Manga) {
+	var downloaded []string
+	for _, chapter := range manga.Chapters {
+		if _, err := os.Stat(chapterArchive(*manga, chapter)); err == nil {
+			downloaded = append(downloaded, fmt.Sprintf("%.1f", chapter))
+		}
+	}
+
+	preview := &canvas.Image{FillMode: canvas.ImageFillContain}
+	preview.SetMinSize(fyne.NewSize(currentConfig().Config.ThumbnailWidth, currentConfig().Config.ThumbnailHeight))
+	var selected []byte
+
+	chapterSelect := widget.NewSelect(downloaded, nil)
+	pageEntry := widget.NewEntry()
+	pageEntry.SetText("1")
+	updatePreview := func() {
+		selected = nil
+		preview.Image = nil
+		chapter, err1 := strconv.ParseFloat(chapterSelect.Selected, 64)
+		page, err2 := strconv.Atoi(strings.TrimSpace(pageEntry.Text))
+		if err1 == nil && err2 == nil && page > 0 {
+			data, err := readChapterPage(*manga, chapter, page-1)
+			if err == nil {
+				if thumbnail, err := makeThumbnail(data, currentConfig().Config.ThumbnailWidth, currentConfig().Config.ThumbnailHeight); err == nil {
+					preview.Image, _, _ = image.Decode(bytes.NewReader(thumbnail))
+					selected = data
+				}
+			}
+		}
+		preview.Refresh()
+	}
+	chapterSelect.OnChanged = func(string) { updatePreview() }
+	pageEntry.OnChanged = func(string) { updatePreview() }
+	if len(downloaded) > 0 {
+		chapterSelect.SetSelected(downloaded[0])
+	}
+
+	var coverDialog dialog.Dialog
+	usePage := widget.NewButton("Use this page", func() {
+		if selected == nil {
+			dialog.ShowError(errors.New("choose a downloaded chapter and a page first"), mainWindow)
+			return
+		}
+		if err := setCustomCover(manga, selected); err != nil {
+			dialog.ShowError(err, mainWindow)
+			return
+		}
+		coverDialog.Hide()
+	})
+	useFile := widget.NewButton("Choose an image file...", func() {
+		fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil || reader == nil {
+				return
+			}
+			defer reader.Close()
+			data, err := io.ReadAll(reader)
+			if err == nil {
+				err = setCustomCover(manga, data)
+			}
+			if err != nil {
+				dialog.ShowError(err, mainWindow)
+				return
+			}
+			coverDialog.Hide()
+		}, mainWindow)
+		fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".jpg", ".jpeg", ".png", ".gif", ".webp"}))
+		fileDialog.Show()
+	})
+	reset := widget.NewButton("Use the provider cover", func() {
+		resetCustomCover(manga)
+		coverDialog.Hide()
+	})
+	if manga.CustomCover == "" {
+		reset.Disable()
+	}
+
+	form := widget.NewForm(
+		widget.NewFormItem("Chapter", chapterSelect),
+		widget.NewFormItem("Page", pageEntry),
+	)
+	content := container.NewBorder(nil, container.NewHBox(usePage, useFile, reset), nil, form, preview)
+	coverDialog = dialog.NewCustom(fmt.Sprintf("Cover of %s", manga.Name), "Close", content, mainWindow)
+	coverDialog.Show()
+}
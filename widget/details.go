@@ -0,0 +1,112 @@
+package widget
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/francoiscolombo/gomangareader/settings"
+)
+
+// detailLabels are the labels of the details that the user can correct, see settings.OverridableFields
+var detailLabels = map[string]string{
+	"name":            "Name",
+	"alternate_name":  "Alternate Name",
+	"year_of_release": "Year of release",
+	"status":          "Status",
+	"author":          "Author",
+	"artist":          "Artist",
+	"description":     "Description",
+	"genres":          "Genres",
+	"tags":            "Tags",
+	"content_rating":  "Content rating",
+}
+
+// detailLabel returns the label of a detail, with an indicator when the user corrected it
+func detailLabel(manga settings.Manga, field string) string {
+	if manga.IsOverridden(field) {
+		return fmt.Sprintf("%s (locked)", detailLabels[field])
+	}
+	return detailLabels[field]
+}
+
+/*
+showDetailsDialog lets the user correct the details of a manga. the corrected details are locked: they
+are kept when the details are read again from the provider, until they are reset.
+*/
+func showDetailsDialog(manga *settings.Manga) {
+	entries := map[string]*widget.Entry{}
+	var items []*widget.FormItem
+	for _, field := range settings.OverridableFields {
+		field := field
+		entry := widget.NewEntry()
+		if field == "description" {
+			entry = widget.NewMultiLineEntry()
+			entry.Wrapping = fyne.TextWrapWord
+			entry.SetMinRowsVisible(6)
+		} else if field == "genres" || field == "tags" {
+			entry.SetPlaceHolder("separated by commas")
+		}
+		entry.SetText(manga.Detail(field))
+		entries[field] = entry
+		reset := widget.NewButtonWithIcon("", theme.ContentUndoIcon(), func() {
+			entry.SetText(manga.ProviderValue(field))
+		})
+		items = append(items, widget.NewFormItem(detailLabel(*manga, field), container.NewBorder(nil, nil, nil, reset, entry)))
+	}
+	form := dialog.NewForm(fmt.Sprintf("Details of %s", manga.Name), "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		for _, field := range settings.OverridableFields {
+			value := entries[field].Text
+			if value == manga.Detail(field) {
+				continue
+			}
+			manga.SetOverride(field, value)
+		}
+		detailsChanged(manga)
+	}, mainWindow)
+	form.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*6, currentConfig().Config.ThumbnailHeight*3))
+	form.Show()
+}
+
+// resetDetails removes all the corrections of the user, after a confirmation
+func resetDetails(manga *settings.Manga) {
+	dialog.ShowConfirm("Reset to provider",
+		fmt.Sprintf("The corrections of the details of %s will be lost, the details of the provider will be used again.", manga.Name),
+		func(ok bool) {
+			if !ok {
+				return
+			}
+			manga.ResetOverrides()
+			detailsChanged(manga)
+		}, mainWindow)
+}
+
+// detailsChanged saves the corrected details of a manga, and refreshes the widgets displaying them
+func detailsChanged(manga *settings.Manga) {
+	updateManga(manga.Title, func(m *settings.Manga) {
+		for _, field := range settings.OverridableFields {
+			m.SetDetail(field, manga.Detail(field))
+		}
+		m.Overrides, m.ProviderValues = manga.Overrides, manga.ProviderValues
+	})
+	if library != nil {
+		for _, tb := range library.Items {
+			if tb.Title.Title == manga.Title && tb.Title != manga {
+				for _, field := range settings.OverridableFields {
+					tb.Title.SetDetail(field, manga.Detail(field))
+				}
+				tb.Title.Overrides = manga.Overrides
+				tb.Title.ProviderValues = manga.ProviderValues
+			}
+			tb.Refresh()
+		}
+	}
+	if series != nil {
+		series.Refresh()
+	}
+}
@@ -1,6 +1,7 @@
 package widget
 
 import (
+	"errors"
 	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -11,10 +12,10 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,6 +26,8 @@ type Downloader struct {
 	CurrentPage     int
 	TotalPages      int
 	Successful      bool
+	// downloading is 1 while the chapters are downloaded, so the download is not started twice
+	downloading int32
 }
 
 func NewDownloader(manga *settings.Manga, chapter int) *Downloader {
@@ -47,11 +50,8 @@ func (d *Downloader) MinSize() fyne.Size {
 
 func (d *Downloader) CreateRenderer() fyne.WidgetRenderer {
 	d.ExtendBaseWidget(d)
-	var page *canvas.Image
-	if d.SelectedManga.CoverPath != "" {
-		page = canvas.NewImageFromFile(d.SelectedManga.CoverPath)
-		page.FillMode = canvas.ImageFillContain
-	}
+	page := canvas.NewImageFromFile(coverFile(*d.SelectedManga))
+	page.FillMode = canvas.ImageFillContain
 
 	label := canvas.NewText("Please wait, downloading pages now...", theme.ForegroundColor())
 	label.TextSize = 12
@@ -70,7 +70,14 @@ func (d *Downloader) CreateRenderer() fyne.WidgetRenderer {
 	description.Alignment = fyne.TextAlignCenter
 
 	download := widget.NewButtonWithIcon("Download chapters...", theme.DownloadIcon(), func() {
-		d.ChapterDownloader()
+		if !atomic.CompareAndSwapInt32(&d.downloading, 0, 1) {
+			return
+		}
+		// the progress is displayed by the downloader, the window stays responsive
+		go func() {
+			defer atomic.StoreInt32(&d.downloading, 0)
+			d.ChapterDownloader()
+		}()
 	})
 
 	bg := canvas.NewRectangle(theme.ButtonColor())
@@ -91,89 +98,48 @@ func (d *Downloader) CreateRenderer() fyne.WidgetRenderer {
 
 func (d *Downloader) ChapterDownloader() {
 	provider := settings.MangaReader{}
-	imageLinks := provider.GetPagesUrls(*d.SelectedManga)
-	// okay now we have all images links, so download all the images... if we have any
-	if len(imageLinks) > 0 {
-		tempDirectory, err := ioutil.TempDir("", d.SelectedManga.Title)
-		if err == nil {
-			d.CurrentPage = 0
-			d.TotalPages = len(imageLinks)
-			for {
-				nbJobs := config.Config.NbWorkers
-				if (d.CurrentPage + nbJobs) > d.TotalPages {
-					nbJobs = d.TotalPages - d.CurrentPage
-				}
-				jobs := make(chan downloadWorkerJob, nbJobs)
-				results := make(chan downloadWorkerResult, nbJobs)
-				wg := sync.WaitGroup{}
-				for i := 0; i < nbJobs; i++ {
-					wg.Add(1)
-					go func() {
-						defer wg.Done()
-						downloadWorker(jobs, results)
-					}()
-				}
-				go func() {
-					defer close(results)
-					wg.Wait()
-				}()
-				go func() {
-					defer close(jobs)
-					for i := 0; i < nbJobs; i++ {
-						job := downloadWorkerJob{
-							path:       tempDirectory,
-							page:       d.CurrentPage,
-							url:        imageLinks[d.CurrentPage],
-							downloader: d,
-						}
-						jobs <- job
-						d.CurrentPage++
-					}
-				}()
-				for res := range results {
-					if res.error != nil {
-						d.Successful = false
-						log.Printf("Status code %d when downloading page %d from url:\n%s\nthe error is: %s", res.statusCode, d.CurrentPage, res.url, res.error)
-						break
-					}
-				}
-				if d.CurrentPage >= d.TotalPages {
-					break
-				}
-			}
-			// and now create the new cbz from that temporary directory
-			err = createCBZ(d.SelectedManga.Path, tempDirectory, d.SelectedManga.Title, d.SelectedManga.LastChapter)
-			if err != nil {
-				log.Printf("Error when trying to create chapter %03.1f of %s from %s\nthe error is: %s", d.SelectedManga.LastChapter, d.SelectedManga.Title, tempDirectory, err)
-				d.Successful = false
-				return
-			}
-			// update history
-			lastChapterIndex := -1
-			for i := 0; i < len(d.SelectedManga.Chapters); i++ {
-				if d.SelectedManga.Chapters[i] > d.SelectedManga.LastChapter {
-					lastChapterIndex = i
-					break
-				}
+	d.CurrentPage = 0
+	d.TotalPages = 1
+	nbPages, cbzPath, err := downloadChapter(*d.SelectedManga, d.SelectedManga.LastChapter, false, func(done, total int) {
+		d.CurrentPage = done
+		d.TotalPages = total
+		d.Refresh()
+	})
+	if err != nil {
+		log.Printf("Error when trying to download chapter %03.1f of %s\nthe error is: %s", d.SelectedManga.LastChapter, d.SelectedManga.Title, err)
+		d.Successful = false
+	} else {
+		recordDownload(settings.DownloadRecord{
+			Title:   d.SelectedManga.Title,
+			Name:    d.SelectedManga.Name,
+			Chapter: d.SelectedManga.LastChapter,
+			Date:    time.Now(),
+		})
+		d.SelectedManga.SetPageCount(d.SelectedManga.LastChapter, nbPages)
+		d.SelectedManga.SetChapterFile(d.SelectedManga.LastChapter, cbzPath)
+		// update history
+		lastChapterIndex := -1
+		for i := 0; i < len(d.SelectedManga.Chapters); i++ {
+			if d.SelectedManga.Chapters[i] > d.SelectedManga.LastChapter {
+				lastChapterIndex = i
+				break
 			}
-			if lastChapterIndex >= 0 {
-				d.SelectedManga.LastChapter = d.SelectedManga.Chapters[lastChapterIndex]
-				*config = settings.UpdateHistory(*config, *d.SelectedManga)
-				if d.SelectedManga.LastChapter <= provider.CheckLastChapter(*d.SelectedManga) {
-					d.CurrentPage = 0
-					d.TotalPages = 1
-					d.DownloadChapter = lastChapterIndex
-					d.Refresh()
-				}
-				err = extractFirstPages(config.Config.LibraryPath, *d.SelectedManga)
-				if err != nil {
-					log.Printf("Error happened while extracting first page for %s\n%s", d.SelectedManga.Name, err)
-					d.Successful = false
-				}
-				chapters.Refresh()
-			} else {
-				d.Successful = false
+		}
+		if lastChapterIndex >= 0 {
+			d.SelectedManga.LastChapter = d.SelectedManga.Chapters[lastChapterIndex]
+			updateManga(d.SelectedManga.Title, func(manga *settings.Manga) {
+				manga.LastChapter = d.SelectedManga.LastChapter
+			})
+			if d.SelectedManga.LastChapter <= provider.CheckLastChapter(*d.SelectedManga) {
+				d.CurrentPage = 0
+				d.TotalPages = 1
+				d.DownloadChapter = lastChapterIndex
+				d.Refresh()
 			}
+			extractFirstPages(*d.SelectedManga)
+			chapters.Refresh()
+		} else {
+			d.Successful = false
 		}
 	}
 	if d.Successful == true {
@@ -215,7 +181,7 @@ func (d *DownloaderRenderer) Destroy() {
 }
 
 func (d *DownloaderRenderer) MinSize() fyne.Size {
-	return fyne.NewSize(config.Config.ThumbnailWidth*6+theme.Padding()*2, config.Config.ThumbMiniHeight+theme.Padding()*2)
+	return fyne.NewSize(currentConfig().Config.ThumbnailWidth*6+theme.Padding()*2, currentConfig().Config.ThumbMiniHeight+theme.Padding()*2)
 }
 
 func (d *DownloaderRenderer) Objects() []fyne.CanvasObject {
@@ -236,22 +202,22 @@ func (d *DownloaderRenderer) Layout(_ fyne.Size) {
 	dy := p
 	txtHeight = 20.0
 
-	d.page.Resize(fyne.NewSize(config.Config.ThumbMiniWidth, config.Config.ThumbMiniHeight))
+	d.page.Resize(fyne.NewSize(currentConfig().Config.ThumbMiniWidth, currentConfig().Config.ThumbMiniHeight))
 	d.page.Move(fyne.NewPos(dx, dy))
-	dx = dx + config.Config.ThumbMiniWidth + p
+	dx = dx + currentConfig().Config.ThumbMiniWidth + p
 
-	d.download.Resize(fyne.NewSize(200, config.Config.ThumbMiniHeight/2-p))
-	d.download.Move(fyne.NewPos(config.Config.ThumbnailWidth*6-200-p, dy))
+	d.download.Resize(fyne.NewSize(200, currentConfig().Config.ThumbMiniHeight/2-p))
+	d.download.Move(fyne.NewPos(currentConfig().Config.ThumbnailWidth*6-200-p, dy))
 
-	d.label.Resize(fyne.NewSize(config.Config.ThumbnailWidth*5-200, txtHeight))
+	d.label.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*5-200, txtHeight))
 	d.label.Move(fyne.NewPos(dx, dy))
 	dy = dy + txtHeight + p
 
-	d.progress.Resize(fyne.NewSize(config.Config.ThumbnailWidth*5-200, txtHeight))
+	d.progress.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*5-200, txtHeight))
 	d.progress.Move(fyne.NewPos(dx, dy))
 	dy = dy + txtHeight + p
 
-	d.description.Resize(fyne.NewSize(config.Config.ThumbnailWidth*5, txtHeight*2))
+	d.description.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*5, txtHeight*2))
 	d.description.Move(fyne.NewPos(dx, dy))
 }
 
@@ -272,39 +238,152 @@ func (d *DownloaderRenderer) Refresh() {
 	d.description.Refresh()
 }
 
-type downloadWorkerJob struct {
-	path       string
-	page       int
-	url        string
-	downloader *Downloader
+// maxDownloadAttempts is the number of times a page is requested before giving up on the chapter
+const maxDownloadAttempts = 3
+
+/*
+saveDownload records the number of pages and the file name of a downloaded chapter with the manga of the
+history. when the manga was removed from the library during the download, its archive is removed too.
+*/
+/*
+seriesLocks serializes the changes of the files of a series: a downloaded chapter written in its folder,
+and the removal or the move of the folder. a download never writes in a folder being moved.
+*/
+var seriesLocks sync.Map
+
+// lockSeries takes the lock of the files of a series, and returns the function releasing it
+func lockSeries(title string) func() {
+	value, _ := seriesLocks.LoadOrStore(title, &sync.Mutex{})
+	mutex := value.(*sync.Mutex)
+	mutex.Lock()
+	return mutex.Unlock
 }
 
-type downloadWorkerResult struct {
-	error      error
-	url        string
-	statusCode int
+func saveDownload(title string, chapter float64, cbzPath string, nbPages int) error {
+	_, found := updateManga(title, func(manga *settings.Manga) {
+		manga.SetPageCount(chapter, nbPages)
+		manga.SetChapterFile(chapter, cbzPath)
+	})
+	if !found {
+		_ = os.Remove(cbzPath)
+		// the series folder is removed only if it is empty
+		_ = os.Remove(filepath.Dir(cbzPath))
+		return errors.New(fmt.Sprintf("%s was removed from the library during the download", title))
+	}
+	return nil
 }
 
-func downloadWorker(jobs <-chan downloadWorkerJob, results chan<- downloadWorkerResult) {
-	for workerJob := range jobs {
-		//log.Printf("Start download page %d from url %s in temp directory %s\n",workerJob.page,workerJob.url,workerJob.path)
-		status, err := downloadImage(workerJob.path, workerJob.page, workerJob.url, workerJob.downloader)
-		//log.Printf("Page %d downloaded with status code %d\n",workerJob.page,status)
-		res := downloadWorkerResult{error: err, url: workerJob.url, statusCode: status}
-		results <- res
+/*
+downloadChapter fetch all the pages of a chapter through the download pool, then build the cbz archive.
+every page is submitted at once, so the workers are never waiting for the slowest page of a batch.
+scheduled downloads, the ones of the queue, are paused outside of the download windows; the downloads
+asked by the user are not. onPage is called each time a page has been downloaded. the archive is written
+in the current folder of the manga and saved in the history while holding the lock of the series, and the
+download stops when the manga is removed. it returns the number of pages and the archive of the chapter.
+*/
+func downloadChapter(manga settings.Manga, chapter float64, scheduled bool, onPage func(done, total int)) (int, string, error) {
+	if !currentConfig().Config.RootAvailable(manga.Root) {
+		return 0, "", errors.New(fmt.Sprintf("the library root %s of %s is offline", manga.RootName(), manga.Name))
+	}
+	provider := settings.MangaReader{}
+	manga.LastChapter = chapter
+	imageLinks := provider.GetPagesUrls(manga)
+	if len(imageLinks) == 0 {
+		return 0, "", errors.New(fmt.Sprintf("no pages found for chapter %03.1f of %s", chapter, manga.Title))
+	}
+	tempDirectory, err := ioutil.TempDir("", manga.Title)
+	if err != nil {
+		return 0, "", err
+	}
+	removed := errors.New(fmt.Sprintf("%s was removed from the library during the download", manga.Title))
+	results := make([]<-chan error, len(imageLinks))
+	for i, link := range imageLinks {
+		page := i
+		link := link
+		job := func() error {
+			// the pages left are not downloaded once the manga is removed
+			if _, found := findManga(manga.Title); !found {
+				return removed
+			}
+			return downloadImage(tempDirectory, page, link, manga.Provider)
+		}
+		if scheduled {
+			results[i] = getDownloadPool().SubmitScheduled(hostOf(link), job)
+		} else {
+			results[i] = getDownloadPool().Submit(hostOf(link), job)
+		}
 	}
+	var failure error
+	for i, result := range results {
+		err := <-result
+		if err != nil && failure == nil {
+			failure = errors.New(fmt.Sprintf("unable to download page %d from url %s: %s", i, imageLinks[i], err))
+		}
+		if onPage != nil {
+			onPage(i+1, len(imageLinks))
+		}
+	}
+	// the folder of the manga can not be removed or moved while the archive is written
+	unlock := lockSeries(manga.Title)
+	defer unlock()
+	current, found := findManga(manga.Title)
+	if !found && failure == nil {
+		// the series folder must not be created again
+		failure = removed
+	}
+	if failure != nil {
+		// never build an archive with missing or corrupted pages
+		err = os.RemoveAll(tempDirectory)
+		if err != nil {
+			log.Printf("Error when trying to remove temporary directory %s, error is %s", tempDirectory, err)
+		}
+		return 0, "", failure
+	}
+	// and now create the new cbz from that temporary directory, in the folder where the manga is now
+	manga.Path, manga.Root, manga.ChapterFiles = current.Path, current.Root, current.ChapterFiles
+	cbzPath := chapterArchive(manga, chapter)
+	if err = createCBZ(cbzPath, tempDirectory, manga, chapter); err != nil {
+		_ = os.RemoveAll(tempDirectory)
+		return 0, "", err
+	}
+	return len(imageLinks), cbzPath, saveDownload(manga.Title, chapter, cbzPath, len(imageLinks))
+}
+
+/*
+retryableError is an error of a page download that may not happen again: a network error or an error
+of the server. the pages rejected by the checks are not requested again, they would be the same.
+*/
+type retryableError struct {
+	err error
 }
 
-func downloadImage(path string, page int, url string, downloader *Downloader) (int, error) {
-	tr := &http.Transport{
-		MaxIdleConns:       10,
-		IdleConnTimeout:    30 * time.Second,
-		DisableCompression: true,
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
+
+func downloadImage(path string, page int, url string, provider string) error {
+	var err error
+	for attempt := 1; attempt <= maxDownloadAttempts; attempt++ {
+		err = fetchPage(path, page, url, provider)
+		if err == nil {
+			break
+		}
+		if _, ok := err.(retryableError); !ok {
+			log.Printf("Page %d from %s is rejected, error is %s", page, url, err)
+			break
+		}
+		log.Printf("Attempt %d/%d to download page %d from %s failed, error is %s", attempt, maxDownloadAttempts, page, url, err)
+		if attempt < maxDownloadAttempts {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
 	}
-	client := &http.Client{Transport: tr}
-	resp, err := client.Get(url)
+	return err
+}
+
+func fetchPage(path string, page int, url string, provider string) error {
+	resp, err := httpClient.Get(url)
 	if err != nil {
-		return -1, err
+		return retryableError{err}
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -313,20 +392,33 @@ func downloadImage(path string, page int, url string, downloader *Downloader) (i
 		}
 	}(resp.Body)
 
-	out, err := os.Create(filepath.FromSlash(fmt.Sprintf("%s/page_%03d.jpg", path, page)))
-	if err != nil {
-		return -1, err
+	if resp.StatusCode >= 500 {
+		return retryableError{errors.New(fmt.Sprintf("unexpected status %s", resp.Status))}
+	}
+	if resp.StatusCode != 200 {
+		return errors.New(fmt.Sprintf("unexpected status %s", resp.Status))
 	}
-	defer func(out *os.File) {
-		err := out.Close()
-		if err != nil {
-			log.Printf("Something went wront while trying to close http client, error is %s", err)
-		}
-	}(out)
 
-	_, err = io.Copy(out, resp.Body)
+	data, err := ioutil.ReadAll(io.LimitReader(throttle(resp.Body, provider), maxPageSize+1))
+	if err != nil {
+		return retryableError{err}
+	}
+	if len(data) > maxPageSize {
+		return errors.New(fmt.Sprintf("page is bigger than %d bytes", maxPageSize))
+	}
+	if resp.ContentLength > 0 && int64(len(data)) != resp.ContentLength {
+		return retryableError{errors.New(fmt.Sprintf("page is truncated, got %d bytes instead of %d", len(data), resp.ContentLength))}
+	}
 
-	downloader.Refresh()
+	format, err := detectImageFormat(data, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	err = validateImage(data, format)
+	if err != nil {
+		return err
+	}
 
-	return resp.StatusCode, err
+	err = ioutil.WriteFile(filepath.FromSlash(fmt.Sprintf("%s/page_%03d.%s", path, page, format)), data, 0644)
+	return err
 }
@@ -0,0 +1,181 @@
+package widget
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/francoiscolombo/gomangareader/archive"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"mime"
+	"strings"
+)
+
+const (
+	minPageDimension = 16
+	maxPageDimension = 30000
+	maxPageSize      = 64 << 20
+)
+
+/*
+detectImageFormat sniff the magic bytes of a downloaded page to find its real format, and use the
+Content-Type header only when the signature is unknown. it returns the extension to use for the page.
+the formats that archive.IsPage does not accept as pages are rejected.
+*/
+func detectImageFormat(data []byte, contentType string) (string, error) {
+	if format := sniffImageFormat(data); format != "" {
+		if !archive.IsPage("page." + format) {
+			return format, errors.New(fmt.Sprintf("%s pages are not supported, they can not be decoded", format))
+		}
+		return format, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	if strings.HasPrefix(mediaType, "image/") {
+		return "", errors.New(fmt.Sprintf("content type is %s but the data does not look like a valid image", mediaType))
+	}
+	return "", errors.New(fmt.Sprintf("content type %s is not an image", mediaType))
+}
+
+/*
+validateImage fully decode a page to ensure that it is not truncated or corrupted, and that its
+dimensions are sane. there is no AVIF decoder: an AVIF page is only checked from its header and the
+dimensions it declares.
+*/
+func validateImage(data []byte, format string) error {
+	if format == "avif" {
+		width, height, err := avifDimensions(data)
+		if err != nil {
+			return errors.New(fmt.Sprintf("unable to read avif header: %s", err))
+		}
+		return checkPageDimensions(format, width, height)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return errors.New(fmt.Sprintf("%s pages are not supported, they can not be decoded", format))
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to read %s header: %s", format, err))
+	}
+	if err = checkPageDimensions(format, cfg.Width, cfg.Height); err != nil {
+		return err
+	}
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return errors.New(fmt.Sprintf("unable to decode %s page: %s", format, err))
+	}
+	return nil
+}
+
+// checkPageDimensions rejects the pages too small to be a real page, or too big to be displayed
+func checkPageDimensions(format string, width, height int) error {
+	if width < minPageDimension || height < minPageDimension || width > maxPageDimension || height > maxPageDimension {
+		return errors.New(fmt.Sprintf("%s page has unexpected dimensions %dx%d", format, width, height))
+	}
+	return nil
+}
+
+// sniffImageFormat returns the extension of the format of an image from its magic bytes, empty if unknown
+func sniffImageFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8, 0xff}):
+		return "jpg"
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return "gif"
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "webp"
+	case len(data) >= 26 && string(data[0:2]) == "BM":
+		return "bmp"
+	case isAvif(data):
+		return "avif"
+	}
+	return ""
+}
+
+func isAvif(data []byte) bool {
+	if len(data) < 16 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+	size := int(binary.BigEndian.Uint32(data[0:4]))
+	if size < 16 || size > len(data) {
+		return false
+	}
+	// major brand, then minor version, then the list of compatible brands
+	brands := [][]byte{data[8:12]}
+	for i := 16; i+4 <= size; i += 4 {
+		brands = append(brands, data[i:i+4])
+	}
+	for _, brand := range brands {
+		if string(brand) == "avif" || string(brand) == "avis" {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+avifDimensions reads the dimensions declared by an AVIF image, in the ispe property of its meta box. the
+file must start with an ftyp box of the avif brand.
+*/
+func avifDimensions(data []byte) (int, int, error) {
+	if !isAvif(data) {
+		return 0, 0, errors.New("the avif signature is missing")
+	}
+	box := data
+	for _, name := range []string{"meta", "iprp", "ipco", "ispe"} {
+		content, found := findBox(box, name)
+		if !found {
+			return 0, 0, errors.New(fmt.Sprintf("the %s box is missing", name))
+		}
+		if name == "meta" {
+			// meta is a full box, its content starts after its version and flags
+			if len(content) < 4 {
+				return 0, 0, errors.New("the meta box is truncated")
+			}
+			content = content[4:]
+		}
+		box = content
+	}
+	// ispe is a full box too: version and flags, then the width and the height
+	if len(box) < 12 {
+		return 0, 0, errors.New("the ispe box is truncated")
+	}
+	return int(binary.BigEndian.Uint32(box[4:8])), int(binary.BigEndian.Uint32(box[8:12])), nil
+}
+
+/*
+findBox returns the content of the first box of the given type in data, a list of ISO media boxes. a box
+size of 1 is followed by a 64 bits size, a size of 0 means that the box goes to the end of data.
+*/
+func findBox(data []byte, name string) ([]byte, bool) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return nil, false
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return nil, false
+		}
+		if string(data[4:8]) == name {
+			return data[header:size], true
+		}
+		data = data[size:]
+	}
+	return nil, false
+}
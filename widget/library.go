@@ -5,11 +5,11 @@ import (
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
-	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/francoiscolombo/gomangareader/settings"
+	"log"
 )
 
 const (
@@ -18,7 +18,6 @@ const (
 )
 
 // global variables
-var config *settings.Settings
 var application fyne.App
 var mainWindow fyne.Window
 var libraryTabs *container.AppTabs
@@ -29,6 +28,10 @@ var series *Series
 var chapters *Chapters
 var downloader *Downloader
 var reader *Reader
+var preferences fyne.CanvasObject
+
+// libraryFilter is the genre, tag or content rating of the titles displayed in the library, empty for all
+var libraryFilter string
 
 /*
 ShowLibrary allow to display the mangas in a GUI.
@@ -38,23 +41,36 @@ func ShowLibrary() {
 	if settings.IsSettingsExisting() == false {
 		settings.WriteDefaultSettings()
 	}
-	cfg := settings.ReadSettings()
-	config = &cfg
-	if len(config.History.Titles) == 0 {
+	setConfig(settings.ReadSettings())
+	applyArchiveLimits()
+	if nbPurged, err := settings.PurgeTrash(currentConfig().Config); err != nil {
+		log.Printf("Error when trying to empty the trash: %s", err)
+	} else if nbPurged > 0 {
+		log.Printf("%d removed series deleted from the trash", nbPurged)
+	}
+	if len(currentConfig().History.Titles) == 0 {
+		// the settings may have been lost, the library can describe itself
+		nbTitles, err := rebuildHistory()
+		if err != nil {
+			log.Printf("Error when trying to read the sidecars of the library: %s", err)
+		} else if nbTitles > 0 {
+			log.Printf("History rebuilt from the library, %d titles found", nbTitles)
+		}
+	}
+	if len(currentConfig().History.Titles) == 0 {
 		// by default, we add all-you-need-is-kill as the first manga (manga that is at the origin of edge of tomorrow)
 		provider := settings.MangaReader{}
-		newManga := provider.FindDetails(config.Config.LibraryPath, "all-you-need-is-kill", 0)
+		newManga := provider.FindDetails(currentConfig().Config.LibraryPath, "all-you-need-is-kill", 0)
+		newManga.Path = settings.UniqueSeriesPath(currentConfig(), newManga)
 		provider.BuildChaptersList(&newManga)
-		config.History.Titles = append(config.History.Titles, newManga)
-		// download cover picture (if needed)
-		err1 := downloadCover(newManga)
-		if err1 == nil {
-			// and generate thumbnails (if needed)
-			err2 := extractFirstPages(config.Config.LibraryPath, newManga)
-			if err2 != nil {
-				dialog.ShowError(err2, nil)
-			}
+		addManga(newManga)
+		// download cover picture (if needed), or use a fallback
+		err1 := ensureCover(newManga)
+		if err1 != nil {
+			log.Printf("Error when trying to get a cover for %s: %s", newManga.Title, err1)
 		}
+		// and generate thumbnails (if needed)
+		extractFirstPages(newManga)
 	}
 
 	r, _ := fyne.LoadResourceFromPath("./gomangareader.png")
@@ -64,15 +80,15 @@ func ShowLibrary() {
 	progress := widget.NewProgressBar()
 	mangaTitle := widget.NewLabelWithStyle("...", fyne.TextAlignCenter, fyne.TextStyle{Monospace: true})
 	loadLibraryWindow := application.NewWindow(fmt.Sprintf("GoMangaReader v%s (%s)", versionNumber, versionName))
-	if config.Config.AutoUpdate == true {
-		loadLibraryWindow.SetContent(container.New(layout.NewGridWrapLayout(fyne.NewSize(config.Config.PageWidth, config.Config.ThumbTextHeight)),
+	if currentConfig().Config.AutoUpdate == true {
+		loadLibraryWindow.SetContent(container.New(layout.NewGridWrapLayout(fyne.NewSize(currentConfig().Config.PageWidth, currentConfig().Config.ThumbTextHeight)),
 			widget.NewLabelWithStyle("Please wait, we are now loading your library, and updating the metadata", fyne.TextAlignCenter, fyne.TextStyle{Bold: true, Italic: true}),
 			widget.NewLabelWithStyle(" at the same time... It could be long, so be patient.", fyne.TextAlignCenter, fyne.TextStyle{Bold: true, Italic: true}),
 			progress,
 			mangaTitle),
 		)
 	} else {
-		loadLibraryWindow.SetContent(container.New(layout.NewGridWrapLayout(fyne.NewSize(config.Config.PageWidth, config.Config.ThumbTextHeight)),
+		loadLibraryWindow.SetContent(container.New(layout.NewGridWrapLayout(fyne.NewSize(currentConfig().Config.PageWidth, currentConfig().Config.ThumbTextHeight)),
 			widget.NewLabelWithStyle("Please wait, we are now loading your library...", fyne.TextAlignCenter, fyne.TextStyle{Bold: true, Italic: true}),
 			progress,
 			mangaTitle),
@@ -82,15 +98,15 @@ func ShowLibrary() {
 	loadLibraryWindow.Show()
 
 	var searchTab *container.Scroll
-	var libraryTab *container.Scroll
+	var libraryTab fyne.CanvasObject
 	var seriesTab *container.Scroll
 	var readerTab *container.Scroll
 
 	go func() {
 		mainWindow = application.NewWindow(fmt.Sprintf("GoMangaReader v%s (%s)", versionNumber, versionName))
 
-		library = updateLibraryContent(progress, mangaTitle, config.Config.AutoUpdate)
-		libraryTab = container.NewScroll(library)
+		library = updateLibraryContent(progress, mangaTitle, currentConfig().Config.AutoUpdate)
+		libraryTab = newLibraryTab()
 
 		search = NewSearch("mangareader.cc")
 		searchTab = container.NewScroll(search)
@@ -105,54 +121,60 @@ func ShowLibrary() {
 		reader = nil
 		readerTab = container.NewScroll(widget.NewLabel(""))
 
-		configuration := widget.NewButtonWithIcon("Refresh your library...", theme.ViewRefreshIcon(), func() {
+		preferences = newPreferences(widget.NewButtonWithIcon("Refresh your library...", theme.ViewRefreshIcon(), func() {
 			library = updateLibraryContent(progress, mangaTitle, true)
-		})
+		}))
 
 		libraryTabs = container.NewAppTabs(
 			container.NewTabItem("Your Library", libraryTab),
 			container.NewTabItem("Selected manga", seriesTab),
 			container.NewTabItem("Read chapter", readerTab),
 			container.NewTabItem("Search new titles", searchTab),
-			container.NewTabItem("Preferences", configuration),
+			container.NewTabItem("Preferences", container.NewScroll(preferences)),
 		)
 
 		mainWindow.SetContent(libraryTabs)
-		mainWindow.Resize(fyne.NewSize(config.Config.ThumbnailWidth*config.Config.NbColumns+40, (config.Config.ThumbnailHeight+config.Config.ThumbTextHeight)*config.Config.NbRows+config.Config.ThumbTextHeight))
+		mainWindow.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*currentConfig().Config.NbColumns+40, (currentConfig().Config.ThumbnailHeight+currentConfig().Config.ThumbTextHeight)*currentConfig().Config.NbRows+currentConfig().Config.ThumbTextHeight))
 		mainWindow.SetMaster()
 		mainWindow.CenterOnScreen()
 		mainWindow.Show()
 
 		loadLibraryWindow.Close()
+
+		restoreQueue()
+		startUpdateChecker()
+		startLibraryWatcher()
+		startRootsChecker()
 	}()
 
 	application.Run()
 }
 
 func updateLibraryContent(progress *widget.ProgressBar, title *widget.Label, autoUpdate bool) *Titles {
+	checkLibraryRoots()
 	content := NewTitlesContainer()
 	var mangaUpdatedList []settings.Manga
 	var provider settings.MangaProvider
-	nbTitles := float64(len(config.History.Titles))
-	for i, manga := range config.History.Titles {
+	cfg := currentConfig()
+	nbTitles := float64(len(cfg.History.Titles))
+	for i, manga := range cfg.History.Titles {
 		value := float64(i) / nbTitles
 		title.SetText(manga.Name)
 		if autoUpdate {
 			provider = settings.MangaReader{}
-			newManga := provider.FindDetails(config.Config.LibraryPath, manga.Title, manga.LastChapter)
+			newManga := settings.KeepLocalSettings(manga, provider.FindDetails(cfg.Config.LibraryPath, manga.Title, manga.LastChapter))
 			provider.BuildChaptersList(&newManga)
 			mangaUpdatedList = append(mangaUpdatedList, newManga)
-			// download cover picture (if needed)
-			err1 := downloadCover(newManga)
-			if err1 == nil {
-				// and generate thumbnails (if needed)
-				err2 := extractFirstPages(config.Config.LibraryPath, newManga)
-				if err2 == nil {
-					ws := NewTitleButton(manga)
-					//groupTitleButtons = append(groupTitleButtons, ws)
-					content.Add(ws)
-				}
+			// and generate thumbnails (if needed)
+			extractFirstPages(newManga)
+			// download cover picture (if needed), or use a fallback, so the title is always displayed
+			err := ensureCover(newManga)
+			if err != nil {
+				log.Printf("Error when trying to get a cover for %s: %s", newManga.Title, err)
 			}
+			ws := NewTitleButton(newManga)
+			//groupTitleButtons = append(groupTitleButtons, ws)
+			content.Add(ws)
 		} else {
 			ws := NewTitleButton(manga)
 			//groupTitleButtons = append(groupTitleButtons, ws)
@@ -162,33 +184,20 @@ func updateLibraryContent(progress *widget.ProgressBar, title *widget.Label, aut
 	}
 	if autoUpdate {
 		// okay we have updated the metadata, now we can save the config
-		newSettings := settings.Settings{
-			Config: settings.Config{
-				LibraryPath:          config.Config.LibraryPath,
-				AutoUpdate:           config.Config.AutoUpdate,
-				NbColumns:            config.Config.NbColumns,
-				NbRows:               config.Config.NbRows,
-				PageWidth:            config.Config.PageWidth,
-				PageHeight:           config.Config.PageHeight,
-				ThumbMiniWidth:       config.Config.ThumbMiniWidth,
-				ThumbMiniHeight:      config.Config.ThumbMiniHeight,
-				LeftRightButtonWidth: config.Config.LeftRightButtonWidth,
-				ChapterLabelWidth:    config.Config.ChapterLabelWidth,
-				ThumbnailWidth:       config.Config.ThumbnailWidth,
-				ThumbnailHeight:      config.Config.ThumbnailHeight,
-				ThumbTextHeight:      config.Config.ThumbTextHeight,
-				NbWorkers:            config.Config.NbWorkers,
-			},
-			History: settings.History{
-				Titles: mangaUpdatedList,
-			},
-		}
-		settings.WriteSettings(newSettings)
+		saveRefreshedMangas(mangaUpdatedList)
 		//log.Println("> Settings updated.")
 	}
 	return content
 }
 
+// reloadLibrary builds the library again from the history, then displays the tab at tabIndex
+func reloadLibrary(tabIndex int) {
+	library = updateLibraryContent(widget.NewProgressBar(), widget.NewLabel(""), false)
+	if library.Manga != nil {
+		refreshTabsContent(library.Manga, tabIndex)
+	}
+}
+
 func refreshTabsContent(manga *settings.Manga, tabIndex int) {
 	series = NewSeries(manga)
 	series.Refresh()
@@ -217,24 +226,66 @@ func refreshTabsContent(manga *settings.Manga, tabIndex int) {
 	}
 	details.Refresh()
 
-	libraryTab := container.NewScroll(library)
+	libraryTab := newLibraryTab()
 	searchTab := container.NewScroll(search)
 	seriesTab := container.NewScroll(details)
 	readerTab := container.NewScroll(widget.NewLabel(""))
 	if reader != nil {
 		readerTab = container.NewScroll(reader)
 	}
-	configuration := widget.NewLabel("configuration form will be hosted here, if any")
 
 	libraryTabs = container.NewAppTabs(
 		container.NewTabItem("Your Library", libraryTab),
 		container.NewTabItem("Selected manga", seriesTab),
 		container.NewTabItem("Read chapter", readerTab),
 		container.NewTabItem("Search new titles", searchTab),
-		container.NewTabItem("Preferences", configuration),
+		container.NewTabItem("Preferences", container.NewScroll(preferences)),
 	)
 	libraryTabs.SelectIndex(tabIndex)
 	libraryTabs.Refresh()
 
 	mainWindow.SetContent(libraryTabs)
 }
+
+/*
+newLibraryTab returns the content of the library tab: the titles, and a filter on the genres, the tags
+and the content ratings of the mangas in the library.
+*/
+func newLibraryTab() fyne.CanvasObject {
+	const allTitles = "All the titles"
+	filter := widget.NewSelect(append([]string{allTitles}, settings.Keywords(currentConfig().History.Titles)...), nil)
+	if libraryFilter == "" {
+		filter.SetSelected(allTitles)
+	} else {
+		filter.SetSelected(libraryFilter)
+	}
+	filter.OnChanged = func(selected string) {
+		if selected == allTitles {
+			selected = ""
+		}
+		if selected != libraryFilter {
+			libraryFilter = selected
+			library.Refresh()
+		}
+	}
+	const allRoots = "All the roots"
+	roots := widget.NewSelect(append([]string{allRoots}, rootNames()...), nil)
+	if libraryRoot == "" {
+		roots.SetSelected(allRoots)
+	} else {
+		roots.SetSelected(libraryRoot)
+	}
+	roots.OnChanged = func(selected string) {
+		if selected == allRoots {
+			selected = ""
+		}
+		if selected != libraryRoot {
+			libraryRoot = selected
+			library.Refresh()
+		}
+	}
+	if len(currentConfig().Config.Roots) == 0 {
+		roots.Hide()
+	}
+	return container.NewBorder(container.NewHBox(widget.NewLabel("Show:"), filter, roots), nil, nil, nil, container.NewScroll(library))
+}
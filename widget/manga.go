@@ -1,16 +1,20 @@
 package widget
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/francoiscolombo/gomangareader/archive"
 	"github.com/francoiscolombo/gomangareader/settings"
+	"image"
 	"image/color"
+	"log"
 	"os"
 	"path/filepath"
 )
@@ -21,35 +25,62 @@ type Reader struct {
 	Chapter    float64
 	PageNumber int
 	NbPages    int
-	TempDir    string
-	Pages      []string
+	// Archive is the chapter archive, it stays opened until another chapter replaces this reader, see Close
+	Archive *archive.Reader
+	// Pages are the indexes of the pages in the archive, in reading order
+	Pages []int
+	// DoublePages flags the pages, in reading order, declared as double pages by the archive metadata
+	DoublePages []bool
+	// RightToLeft tells in which order two pages are displayed side by side
+	RightToLeft bool
 }
 
 func NewReader(manga *settings.Manga, chapter float64) *Reader {
 
 	application.Preferences().SetFloat(manga.Title, chapter)
 
-	metadataPath := filepath.Dir(manga.CoverPath)
-	tmpDir := filepath.FromSlash(fmt.Sprintf("%s/%s/viewer", metadataPath, manga.Title))
-	cbzPath := filepath.FromSlash(fmt.Sprintf("%s/%s-%03.1f.cbz", manga.Path, manga.Title, chapter))
-	pages, err := archive.Unzip(cbzPath, tmpDir)
+	// the previous versions extracted the pages there, remove what a crash could have left
+	_ = os.RemoveAll(filepath.FromSlash(fmt.Sprintf("%s/%s/viewer", filepath.Dir(manga.CoverPath), manga.Title)))
+
+	cbzPath := chapterArchive(*manga, chapter)
+	var pages []int
+	var doublePages []bool
+	var meta archive.Metadata
+	cbz, err := archive.Open(cbzPath)
 	if err != nil {
-		msg := errors.New(fmt.Sprintf("Error when trying to unzip %s to temporary view folder %s: %s", cbzPath, tmpDir, err))
+		msg := errors.New(fmt.Sprintf("Error when trying to open %s: %s", cbzPath, err))
 		dialog.ShowError(msg, mainWindow)
+	} else {
+		meta, err = cbz.Metadata()
+		if err != nil {
+			log.Printf("Error when trying to read the metadata of %s: %s", cbzPath, err)
+		}
+		if applyMetadata(manga, chapter, meta) {
+			updateManga(manga.Title, func(m *settings.Manga) {
+				applyMetadata(m, chapter, meta)
+			})
+		}
+		// pages are displayed in the order declared by the metadata, if any
+		for _, i := range meta.PageOrder(len(cbz.Pages())) {
+			pages = append(pages, i)
+			doublePages = append(doublePages, meta.IsDoublePage(i))
+		}
 	}
 	pageNumber := application.Preferences().Int(fmt.Sprintf("%s/%.1f/currentpage", manga.Title, chapter))
-	if pageNumber <= 0 {
+	nbPages := len(pages)
+	if pageNumber <= 0 || pageNumber > nbPages {
 		pageNumber = 1
 	}
-	nbPages := len(pages)
 
 	nr := &Reader{
-		Manga:      manga,
-		Chapter:    chapter,
-		PageNumber: pageNumber,
-		NbPages:    nbPages,
-		Pages:      pages,
-		TempDir:    tmpDir,
+		Manga:       manga,
+		Chapter:     chapter,
+		PageNumber:  pageNumber,
+		NbPages:     nbPages,
+		Archive:     cbz,
+		Pages:       pages,
+		DoublePages: doublePages,
+		RightToLeft: meta.Manga != "Yes" && meta.Manga != "No",
 	}
 	nr.ExtendBaseWidget(nr)
 	return nr
@@ -71,13 +102,19 @@ func (r *Reader) CreateRenderer() fyne.WidgetRenderer {
 	displayPage.TextSize = 10
 
 	pageProgress := widget.NewProgressBar()
-	pageProgress.SetValue(float64(r.PageNumber) / float64(r.NbPages))
+	pageProgress.SetValue(r.progress())
 
 	pageView := &canvas.Image{FillMode: canvas.ImageFillContain}
-	pageView.File = r.Pages[r.PageNumber-1]
+	pageView.Image = r.pageImage(r.PageNumber)
+
+	facingView := &canvas.Image{FillMode: canvas.ImageFillContain}
 
 	prev := widget.NewButtonWithIcon("[Prev]", theme.MediaFastRewindIcon(), func() {
-		r.PageNumber--
+		if r.PageNumber > 2 && r.isSpread(r.PageNumber-2) {
+			r.PageNumber -= 2
+		} else {
+			r.PageNumber--
+		}
 		if r.PageNumber < 1 {
 			r.PageNumber = 1
 		}
@@ -86,9 +123,15 @@ func (r *Reader) CreateRenderer() fyne.WidgetRenderer {
 	})
 
 	next := widget.NewButtonWithIcon("[Next]", theme.MediaFastForwardIcon(), func() {
-		r.PageNumber++
-		if r.PageNumber > r.NbPages {
+		if r.isSpread(r.PageNumber) {
+			r.PageNumber += 2
+		} else {
+			r.PageNumber++
+		}
+		if r.PageNumber > r.NbPages && r.NbPages > 0 {
 			r.PageNumber = r.NbPages
+		} else if r.PageNumber > r.NbPages {
+			r.PageNumber = 1
 		}
 		r.Refresh()
 		application.Preferences().SetInt(fmt.Sprintf("%s/%.1f/currentpage", r.Manga.Title, r.Chapter), r.PageNumber)
@@ -97,6 +140,7 @@ func (r *Reader) CreateRenderer() fyne.WidgetRenderer {
 	rr := &ReaderRenderer{
 		bg:           bg,
 		page:         pageView,
+		facing:       facingView,
 		displayPage:  displayPage,
 		pageProgress: pageProgress,
 		previous:     prev,
@@ -111,6 +155,7 @@ func (r *Reader) CreateRenderer() fyne.WidgetRenderer {
 type ReaderRenderer struct {
 	bg           *canvas.Rectangle
 	page         *canvas.Image
+	facing       *canvas.Image
 	displayPage  *canvas.Text
 	pageProgress *widget.ProgressBar
 	previous     *widget.Button
@@ -127,14 +172,11 @@ func (r *ReaderRenderer) MinSize() fyne.Size {
 	return fyne.NewSize(libraryTabs.Size().Width, libraryTabs.Size().Height-r.previous.MinSize().Height)
 }
 
+// Destroy does not close the archive: fyne destroys the renderers hidden for a while, and creates them again
 func (r *ReaderRenderer) Destroy() {
-	err := os.RemoveAll(r.reader.TempDir)
-	if err != nil {
-		msg := errors.New(fmt.Sprintf("Error when trying to remove temporary view folder: %s", err))
-		dialog.ShowError(msg, mainWindow)
-	}
 	r.bg = nil
 	r.page = nil
+	r.facing = nil
 	r.displayPage = nil
 	r.pageProgress = nil
 	r.previous = nil
@@ -149,8 +191,24 @@ func (r *ReaderRenderer) Layout(_ fyne.Size) {
 	dx := p
 	dy := p
 
-	r.page.Resize(fyne.NewSize(libraryTabs.Size().Width-p*2, libraryTabs.Size().Height-p*3-r.previous.MinSize().Height*2))
-	r.page.Move(fyne.NewPos(dx, dy))
+	pageSize := fyne.NewSize(libraryTabs.Size().Width-p*2, libraryTabs.Size().Height-p*3-r.previous.MinSize().Height*2)
+	if r.reader.isSpread(r.reader.PageNumber) {
+		// two pages side by side, the first one on the right for a manga
+		half := fyne.NewSize((pageSize.Width-p)/2, pageSize.Height)
+		first, second := fyne.NewPos(dx, dy), fyne.NewPos(dx+half.Width+p, dy)
+		if r.reader.RightToLeft {
+			first, second = second, first
+		}
+		r.page.Resize(half)
+		r.page.Move(first)
+		r.facing.Resize(half)
+		r.facing.Move(second)
+		r.facing.Show()
+	} else {
+		r.page.Resize(pageSize)
+		r.page.Move(fyne.NewPos(dx, dy))
+		r.facing.Hide()
+	}
 	dy = dy + libraryTabs.Size().Height - p*2 - r.previous.MinSize().Height*2
 
 	//r.displayPage.Resize(r.displayPage.MinSize())
@@ -173,6 +231,7 @@ func (r *ReaderRenderer) Objects() []fyne.CanvasObject {
 	var objects []fyne.CanvasObject
 	objects = append(objects, r.bg)
 	objects = append(objects, r.page)
+	objects = append(objects, r.facing)
 	objects = append(objects, r.previous)
 	//objects = append(objects, r.displayPage)
 	objects = append(objects, r.next)
@@ -184,10 +243,87 @@ func (r *ReaderRenderer) Refresh() {
 	//r.displayPage = canvas.NewText(fmt.Sprintf("Page %d / %d", r.reader.PageNumber, r.reader.NbPages), theme.TextColor())
 	//r.displayPage.Refresh()
 
-	r.pageProgress.SetValue(float64(r.reader.PageNumber) / float64(r.reader.NbPages))
+	r.pageProgress.SetValue(r.reader.progress())
 	r.pageProgress.Refresh()
 
-	r.page = &canvas.Image{FillMode: canvas.ImageFillContain}
-	r.page.File = r.reader.Pages[r.reader.PageNumber-1]
+	r.page.Image = r.reader.pageImage(r.reader.PageNumber)
+	if r.reader.isSpread(r.reader.PageNumber) {
+		r.facing.Image = r.reader.pageImage(r.reader.PageNumber + 1)
+	}
+	r.Layout(r.reader.Size())
 	r.page.Refresh()
+	r.facing.Refresh()
+}
+
+// Close closes the chapter archive, when another chapter replaces this reader
+func (r *Reader) Close() {
+	if r.Archive == nil {
+		return
+	}
+	if err := r.Archive.Close(); err != nil {
+		log.Printf("Error when trying to close the chapter archive: %s", err)
+	}
+	r.Archive = nil
+}
+
+/*
+closeReader closes the chapter displayed by the reader when it belongs to the manga with the given title,
+or whatever the manga when title is empty, so its files can be moved. the reader tab is emptied.
+*/
+func closeReader(title string) {
+	if reader == nil || (title != "" && reader.Manga.Title != title) {
+		return
+	}
+	reader.Close()
+	reader = nil
+	if libraryTabs != nil && len(libraryTabs.Items) > 2 {
+		libraryTabs.Items[2].Content = container.NewScroll(widget.NewLabel(""))
+		libraryTabs.Refresh()
+	}
+}
+
+// progress returns the part of the chapter already read, 0 for a chapter without pages
+func (r *Reader) progress() float64 {
+	if r.NbPages == 0 {
+		return 0
+	}
+	return float64(r.PageNumber) / float64(r.NbPages)
+}
+
+// pageImage reads and decodes a page from the archive, pageNumber starts at 1
+func (r *Reader) pageImage(pageNumber int) image.Image {
+	if r.Archive == nil || pageNumber < 1 || pageNumber > r.NbPages {
+		return nil
+	}
+	data, err := r.Archive.ReadPage(r.Pages[pageNumber-1])
+	if err != nil {
+		log.Printf("Error when trying to read page %d of %s chapter %.1f: %s", pageNumber, r.Manga.Title, r.Chapter, err)
+		return nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Error when trying to decode page %d of %s chapter %.1f: %s", pageNumber, r.Manga.Title, r.Chapter, err)
+		return nil
+	}
+	return img
+}
+
+/*
+isSpread tells if the page is displayed with the next one, side by side. this happens only for the
+chapters whose metadata declare double pages, so the other pages are paired like in the printed book;
+never for the cover, and never for the double pages themselves.
+*/
+func (r *Reader) isSpread(pageNumber int) bool {
+	if pageNumber <= 1 || pageNumber >= r.NbPages {
+		return false
+	}
+	if r.DoublePages[pageNumber-1] || r.DoublePages[pageNumber] {
+		return false
+	}
+	for _, double := range r.DoublePages {
+		if double {
+			return true
+		}
+	}
+	return false
 }
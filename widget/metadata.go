@@ -1,11 +1,11 @@
 package widget
 
 import (
-	"archive/zip"
 	"errors"
 	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/dialog"
+	"github.com/francoiscolombo/gomangareader/archive"
 	"github.com/francoiscolombo/gomangareader/settings"
 	"io"
 	"log"
@@ -13,6 +13,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sync"
 )
 
 /*
@@ -41,45 +42,21 @@ func UpdateMetaData(win fyne.Window, config settings.Settings) {
 			provider = settings.MangaReader{}
 		}
 		if provider != nil {
-			newManga := provider.FindDetails(config.Config.LibraryPath, manga.Title, manga.LastChapter)
+			newManga := settings.KeepLocalSettings(manga, provider.FindDetails(config.Config.LibraryPath, manga.Title, manga.LastChapter))
 			provider.BuildChaptersList(&newManga)
 			mangaUpdatedList = append(mangaUpdatedList, newManga)
-			// download cover picture (if needed)
-			err := downloadCover(newManga)
+			// download cover picture (if needed), or use a fallback
+			err := ensureCover(newManga)
 			if err != nil {
 				dialog.ShowError(err, win)
 			}
 			// and generate thumbnails (if needed)
-			err = extractFirstPages(config.Config.LibraryPath, newManga)
-			if err != nil {
-				dialog.ShowError(err, win)
-			}
+			extractFirstPages(newManga)
 			//fmt.Println(" completed.")
 		}
 	}
 	// okay we have updated the metadata, now we can save the config
-	newSettings := settings.Settings{
-		Config: settings.Config{
-			LibraryPath:          config.Config.LibraryPath,
-			AutoUpdate:           config.Config.AutoUpdate,
-			NbColumns:            config.Config.NbColumns,
-			NbRows:               config.Config.NbRows,
-			PageWidth:            config.Config.PageWidth,
-			PageHeight:           config.Config.PageHeight,
-			ThumbMiniWidth:       config.Config.ThumbMiniWidth,
-			ThumbMiniHeight:      config.Config.ThumbMiniHeight,
-			LeftRightButtonWidth: config.Config.LeftRightButtonWidth,
-			ChapterLabelWidth:    config.Config.ChapterLabelWidth,
-			ThumbnailWidth:       config.Config.ThumbnailWidth,
-			ThumbnailHeight:      config.Config.ThumbnailHeight,
-			ThumbTextHeight:      config.Config.ThumbTextHeight,
-			NbWorkers:            config.Config.NbWorkers,
-		},
-		History: settings.History{
-			Titles: mangaUpdatedList,
-		},
-	}
-	settings.WriteSettings(newSettings)
+	saveRefreshedMangas(mangaUpdatedList)
 	//fmt.Println("> Settings updated.")
 }
 
@@ -95,79 +72,114 @@ func downloadCover(manga settings.Manga) error {
 			return err
 		}
 		//fmt.Printf("- %s does not exists yet, we have to download it....", manga.CoverPath)
-		req, _ := http.NewRequest("GET", manga.CoverUrl, nil)
-		req.Header.Add("cache-control", "no-cache")
-		res, err := http.DefaultClient.Do(req)
+		err = <-getDownloadPool().Submit(hostOf(manga.CoverUrl), func() error {
+			return fetchCover(manga)
+		})
 		if err != nil {
-			return err
-		}
-		defer func(Body io.ReadCloser) {
-			err := Body.Close()
-			if err != nil {
-				log.Printf("Something went wrong when I tried to clse the socket, the error is %s", err)
-			}
-		}(res.Body)
-		if res.StatusCode != 200 {
-			err := errors.New(fmt.Sprintf("status code error while trying to extract images from %s: %d %s\nclick OK to continue...", manga.CoverUrl, res.StatusCode, res.Status))
-			return err
-		} else {
-			//open a file for writing
-			file, err := os.Create(manga.CoverPath)
-			if err != nil {
-				return err
-			}
-			defer func(file *os.File) {
-				err := file.Close()
-				if err != nil {
-					log.Printf("Something went wrong when I tried to close the socket, the error is %s", err)
-				}
-			}(file)
-			// Use io.Copy to just dump the response body to the file. This supports huge files
-			_, err = io.Copy(file, res.Body)
-			if err != nil {
-				return err
-			}
-			//log.Println("- new cover downloaded")
+			// never keep a partial cover
+			_ = os.Remove(manga.CoverPath)
 		}
+		return err
 	}
 	return nil
 }
 
+func fetchCover(manga settings.Manga) error {
+	req, _ := http.NewRequest("GET", manga.CoverUrl, nil)
+	req.Header.Add("cache-control", "no-cache")
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Printf("Something went wrong when I tried to clse the socket, the error is %s", err)
+		}
+	}(res.Body)
+	if res.StatusCode != 200 {
+		err := errors.New(fmt.Sprintf("status code error while trying to extract images from %s: %d %s\nclick OK to continue...", manga.CoverUrl, res.StatusCode, res.Status))
+		return err
+	}
+	//open a file for writing
+	file, err := os.Create(manga.CoverPath)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.Printf("Something went wrong when I tried to close the socket, the error is %s", err)
+		}
+	}(file)
+	// Use io.Copy to just dump the response body to the file. This supports huge files
+	_, err = io.Copy(file, throttle(res.Body, manga.Provider))
+	//log.Println("- new cover downloaded")
+	return err
+}
+
+// thumbnailJobs are the thumbnails being generated in the background
+var thumbnailJobs sync.WaitGroup
+
 /*
-ExtractFirstPage allows to extract the first page of a cbz archive to generate a thumbnail
+ExtractFirstPage allows to extract the first page of a cbz archive to generate a thumbnail. the thumbnails
+are generated again when the archive changed. they are generated in the background and this returns at
+once, the chapters of the manga are refreshed when each thumbnail is written.
 */
-func extractFirstPages(globalPath string, manga settings.Manga) error {
+func extractFirstPages(manga settings.Manga) {
+	cfg := currentConfig()
 	for i := 0; i < len(manga.Chapters); i++ {
-		cbzArchive := filepath.FromSlash(fmt.Sprintf("%s/%s/%s-%03.1f.cbz", globalPath, manga.Title, manga.Title, manga.Chapters[i]))
-		cbzThumbnail := filepath.FromSlash(fmt.Sprintf("%s/.metadata/%s-%03.1f.jpg", globalPath, manga.Title, manga.Chapters[i]))
-		if _, err := os.Stat(cbzThumbnail); os.IsNotExist(err) {
-			r, err := zip.OpenReader(cbzArchive)
-			if err == nil {
-				if len(r.File) == 0 {
-					log.Printf("Error while trying to get first pages for manga %s, chapter %03.1f: no pages to extract. Process abandonned, pass to next one.", manga.Name, manga.Chapters[i])
-					break
-				}
-				f := r.File[0]
-				outFile, err := os.OpenFile(cbzThumbnail, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-				if err != nil {
-					return errors.New(fmt.Sprintf("Error while opening thumbnail %s: %s", cbzThumbnail, err))
-				}
-				rc, err := f.Open()
-				if err != nil {
-					return errors.New(fmt.Sprintf("Error while opening first page of %s: %s", cbzThumbnail, err))
-				}
-				_, err = io.Copy(outFile, rc)
-				_ = outFile.Close()
-				_ = rc.Close()
-				if err != nil {
-					return errors.New(fmt.Sprintf("Error while saving %s: %s", cbzThumbnail, err))
-				}
-				err = r.Close()
-				if err != nil {
-					return errors.New(fmt.Sprintf("Error while closing archive %s: %s", cbzArchive, err))
-				}
+		chapter := manga.Chapters[i]
+		cbzArchive := settings.ChapterArchivePath(cfg.Config, manga, chapter)
+		cbzThumbnail := settings.ThumbnailPath(cfg.Config, manga, chapter)
+		if _, err := os.Stat(cbzArchive); err != nil || !thumbnailStale(cbzThumbnail, cbzArchive) {
+			continue
+		}
+		result := getDownloadPool().Submit("", func() error {
+			return extractFirstPage(cbzArchive, cbzThumbnail)
+		})
+		thumbnailJobs.Add(1)
+		go func() {
+			defer thumbnailJobs.Done()
+			if err := <-result; err != nil {
+				log.Printf("Error happened while extracting first page for %s\n%s", manga.Name, err)
+				return
 			}
+			if chapters != nil && chapters.Title == manga.Title {
+				chapters.Refresh()
+			}
+		}()
+	}
+}
+
+// waitForThumbnails blocks until the thumbnails generated in the background are written, for the command line
+func waitForThumbnails() {
+	thumbnailJobs.Wait()
+}
+
+func extractFirstPage(cbzArchive, cbzThumbnail string) error {
+	r, err := archive.Open(cbzArchive)
+	if err != nil {
+		// chapter not downloaded yet
+		return nil
+	}
+	defer func(r *archive.Reader) {
+		err := r.Close()
+		if err != nil {
+			log.Printf("Error while closing archive %s: %s", cbzArchive, err)
 		}
+	}(r)
+	if len(r.Pages()) == 0 {
+		log.Printf("Error while trying to get first page of %s: no pages to extract. Process abandonned, pass to next one.", cbzArchive)
+		return nil
+	}
+	data, err := r.ReadPage(0)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error while opening first page of %s: %s", cbzThumbnail, err))
+	}
+	err = writeThumbnail(cbzThumbnail, data, currentConfig().Config.ThumbMiniWidth, currentConfig().Config.ThumbMiniHeight)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error while saving %s: %s", cbzThumbnail, err))
 	}
 	return nil
 }
@@ -0,0 +1,106 @@
+package widget
+
+import (
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/francoiscolombo/gomangareader/settings"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// moveLibrary moves the library folder to target, the watcher follows the library to its new place
+func moveLibrary(target string, progress func(done, total int)) error {
+	resume := suspendWatcher()
+	defer resume()
+	closeReader("")
+	// the files are moved without holding the settings, then the new location is applied to the current settings
+	moved, err := settings.MoveLibrary(currentConfig(), target, progress)
+	if libraryPath := moved.Config.LibraryPath; libraryPath != currentConfig().Config.LibraryPath {
+		updateConfig(func(cfg settings.Settings) settings.Settings {
+			cfg = settings.RelocateLibrary(cfg, libraryPath)
+			settings.WriteSettings(cfg)
+			return cfg
+		})
+	}
+	if watcher != nil {
+		watcher.watchLibrary(currentConfig().Config)
+	}
+	return err
+}
+
+/*
+MoveLibrary moves the library folder, with all its chapters and metadata, to target and saves its new
+location. when the library can not be moved in one go, the files are copied and the progress is logged.
+*/
+func MoveLibrary(target string) error {
+	loadConfig()
+	source := currentConfig().Config.LibraryPath
+	lastPercent := -1
+	err := moveLibrary(target, func(done, total int) {
+		if percent := done * 100 / total; percent/10 != lastPercent/10 {
+			log.Printf("%d%% of the library copied (%d/%d files)", percent, done, total)
+			lastPercent = percent
+		}
+	})
+	if currentConfig().Config.LibraryPath != source {
+		log.Printf("Library moved from %s to %s", source, currentConfig().Config.LibraryPath)
+	}
+	return err
+}
+
+/*
+showMoveLibraryDialog asks the user where the library must be moved, then moves it while displaying the
+progress. the library is displayed again from its new location.
+*/
+func showMoveLibraryDialog() {
+	target := widget.NewEntry()
+	target.SetText(currentConfig().Config.LibraryPath)
+	browse := widget.NewButton("Browse...", func() {
+		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err != nil || uri == nil {
+				return
+			}
+			target.SetText(filepath.Join(uri.Path(), filepath.Base(currentConfig().Config.LibraryPath)))
+		}, mainWindow)
+	})
+	items := []*widget.FormItem{
+		widget.NewFormItem("New location", target),
+		widget.NewFormItem("", browse),
+	}
+	form := dialog.NewForm("Move library", "Move", "Cancel", items, func(ok bool) {
+		path := strings.TrimSpace(target.Text)
+		if !ok || path == "" {
+			return
+		}
+		if path == currentConfig().Config.LibraryPath {
+			dialog.ShowError(errors.New("the library is already there"), mainWindow)
+			return
+		}
+		message := fmt.Sprintf("The library will be moved from %s to %s.\nDo you want to continue?", currentConfig().Config.LibraryPath, path)
+		dialog.ShowConfirm("Move library", message, func(ok bool) {
+			if !ok {
+				return
+			}
+			progress := dialog.NewProgress("Move library", fmt.Sprintf("Moving the library to %s...", path), mainWindow)
+			progress.Show()
+			go func() {
+				err := moveLibrary(path, func(done, total int) {
+					progress.SetValue(float64(done) / float64(total))
+				})
+				progress.Hide()
+				reloadLibrary(4)
+				if err != nil {
+					dialog.ShowError(err, mainWindow)
+					return
+				}
+				dialog.ShowInformation("Move library", fmt.Sprintf("The library is now in %s.", currentConfig().Config.LibraryPath), mainWindow)
+			}()
+		}, mainWindow)
+	}, mainWindow)
+	form.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*6, currentConfig().Config.ThumbnailHeight))
+	form.Show()
+}
@@ -0,0 +1,164 @@
+package widget
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// httpClient is shared by all the downloads, so connections to the providers are reused
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        32,
+		MaxIdleConnsPerHost: 8,
+		IdleConnTimeout:     30 * time.Second,
+		DisableCompression:  true,
+	},
+	Timeout: 2 * time.Minute,
+}
+
+var pool *downloadPool
+var poolOnce sync.Once
+
+type downloadTask struct {
+	run   func() error
+	done  chan<- error
+	slots chan struct{}
+}
+
+/*
+downloadPool is a long-lived set of workers shared by every download (pages, covers and thumbnails).
+the number of workers is the global concurrency cap, and every host gets its own cap too, so a single
+provider can not take all the workers. jobs are dispatched as soon as a worker is free.
+*/
+type downloadPool struct {
+	tasks   chan downloadTask
+	perHost int
+	mu      sync.Mutex
+	hosts   map[string]chan struct{}
+	workers int
+	// stop asks a worker to leave the pool when it is resized
+	stop chan struct{}
+}
+
+// getDownloadPool returns the pool, started on first use with the current configuration
+func getDownloadPool() *downloadPool {
+	poolOnce.Do(func() {
+		cfg := currentConfig()
+		pool = newDownloadPool(cfg.Config.NbWorkers, cfg.Config.NbWorkersPerHost)
+	})
+	return pool
+}
+
+func newDownloadPool(nbWorkers, perHost int) *downloadPool {
+	p := &downloadPool{
+		tasks: make(chan downloadTask),
+		hosts: map[string]chan struct{}{},
+		stop:  make(chan struct{}),
+	}
+	p.resize(nbWorkers, perHost)
+	return p
+}
+
+// resizeDownloadPool applies the number of workers of the configuration, after the settings were changed
+func resizeDownloadPool() {
+	cfg := currentConfig()
+	getDownloadPool().resize(cfg.Config.NbWorkers, cfg.Config.NbWorkersPerHost)
+}
+
+/*
+resize changes the number of workers and the per host cap. the jobs running are not interrupted: the
+workers removed leave once their job is done, and the jobs holding a slot of the previous per host cap
+release it when they are done.
+*/
+func (p *downloadPool) resize(nbWorkers, perHost int) {
+	if nbWorkers < 1 {
+		nbWorkers = 1
+	}
+	if perHost < 1 || perHost > nbWorkers {
+		perHost = nbWorkers
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if perHost != p.perHost {
+		p.perHost = perHost
+		p.hosts = map[string]chan struct{}{}
+	}
+	for ; p.workers < nbWorkers; p.workers++ {
+		go p.work()
+	}
+	for ; p.workers > nbWorkers; p.workers-- {
+		go func() {
+			p.stop <- struct{}{}
+		}()
+	}
+}
+
+/*
+Submit queue a job for the given host (an empty host means a local job, which is only limited by
+the global cap). the returned channel receives the result of the job once it has been processed.
+*/
+func (p *downloadPool) Submit(host string, run func() error) <-chan error {
+	done := make(chan error, 1)
+	go p.dispatch(host, run, done)
+	return done
+}
+
+/*
+SubmitScheduled is like Submit, but the job is only dispatched during the download windows. a chapter
+download is then paused when a window closes, and resumed when the next one opens.
+*/
+func (p *downloadPool) SubmitScheduled(host string, run func() error) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		waitForDownloadWindow()
+		p.dispatch(host, run, done)
+	}()
+	return done
+}
+
+func (p *downloadPool) dispatch(host string, run func() error, done chan<- error) {
+	var slots chan struct{}
+	if host != "" {
+		slots = p.hostSlots(host)
+		slots <- struct{}{}
+	}
+	p.tasks <- downloadTask{run: run, done: done, slots: slots}
+}
+
+func (p *downloadPool) hostSlots(host string) chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	slots, ok := p.hosts[host]
+	if !ok {
+		slots = make(chan struct{}, p.perHost)
+		p.hosts[host] = slots
+	}
+	return slots
+}
+
+func (p *downloadPool) work() {
+	for {
+		select {
+		case <-p.stop:
+			return
+		case task := <-p.tasks:
+			err := task.run()
+			if task.slots != nil {
+				<-task.slots
+			}
+			task.done <- err
+		}
+	}
+}
+
+// hostOf returns the host part of an url, used to apply the per host cap
+func hostOf(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+	return u.Host
+}
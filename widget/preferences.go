@@ -0,0 +1,361 @@
+package widget
+
+import (
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/francoiscolombo/gomangareader/settings"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+newPreferences build the content of the preferences tab: the given actions (like refreshing the
+library) followed by the configuration form.
+*/
+func newPreferences(actions ...fyne.CanvasObject) fyne.CanvasObject {
+	bandwidth := widget.NewEntry()
+	bandwidth.SetText(strconv.Itoa(currentConfig().Config.BandwidthLimit))
+
+	providerBandwidth := widget.NewEntry()
+	providerBandwidth.SetPlaceHolder("mangareader.cc=200")
+	providerBandwidth.SetText(formatProviderLimits(currentConfig().Config.ProviderBandwidthLimits))
+
+	windows := widget.NewEntry()
+	windows.SetPlaceHolder("01:00-07:00")
+	windows.SetText(formatDownloadWindows(currentConfig().Config.DownloadWindows))
+
+	updateInterval := widget.NewEntry()
+	updateInterval.SetText(strconv.Itoa(currentConfig().Config.UpdateCheckInterval))
+
+	autoDownload := widget.NewCheck("Download new chapters automatically", nil)
+	autoDownload.Checked = currentConfig().Config.AutoDownload
+
+	autoDownloadLimit := widget.NewEntry()
+	autoDownloadLimit.SetText(strconv.Itoa(currentConfig().Config.AutoDownloadLimit))
+
+	trashRetention := widget.NewEntry()
+	trashRetention.SetText(strconv.Itoa(currentConfig().Config.TrashRetentionDays()))
+
+	libraryRoots := widget.NewMultiLineEntry()
+	libraryRoots.SetPlaceHolder("nas=/mnt/nas/mangas")
+	libraryRoots.SetText(formatLibraryRoots(currentConfig().Config.Roots))
+
+	form := &widget.Form{
+		Items: []*widget.FormItem{
+			{Text: "Bandwidth limit (KB/s, 0 = unlimited)", Widget: bandwidth},
+			{Text: "Bandwidth limit per provider (KB/s)", Widget: providerBandwidth},
+			{Text: "Download windows", Widget: windows},
+			{Text: "Check for new chapters every (minutes, 0 = never)", Widget: updateInterval},
+			{Text: "Default for new chapters", Widget: autoDownload},
+			{Text: "Chapters downloaded automatically per check (0 = no limit)", Widget: autoDownloadLimit},
+			{Text: "Keep the removed series in the trash (days)", Widget: trashRetention},
+			{Text: "Other library roots (one name=path per line)", Widget: libraryRoots},
+		},
+		OnSubmit: func() {
+			limit, err := strconv.Atoi(strings.TrimSpace(bandwidth.Text))
+			if err != nil || limit < 0 {
+				dialog.ShowError(errors.New(fmt.Sprintf("invalid bandwidth limit %s", bandwidth.Text)), mainWindow)
+				return
+			}
+			providerLimits, err := parseProviderLimits(providerBandwidth.Text)
+			if err != nil {
+				dialog.ShowError(err, mainWindow)
+				return
+			}
+			downloadWindows, err := parseDownloadWindows(windows.Text)
+			if err != nil {
+				dialog.ShowError(err, mainWindow)
+				return
+			}
+			interval, err := strconv.Atoi(strings.TrimSpace(updateInterval.Text))
+			if err != nil || interval < 0 {
+				dialog.ShowError(errors.New(fmt.Sprintf("invalid update check interval %s", updateInterval.Text)), mainWindow)
+				return
+			}
+			downloadLimit, err := strconv.Atoi(strings.TrimSpace(autoDownloadLimit.Text))
+			if err != nil || downloadLimit < 0 {
+				dialog.ShowError(errors.New(fmt.Sprintf("invalid number of chapters %s", autoDownloadLimit.Text)), mainWindow)
+				return
+			}
+			retention, err := strconv.Atoi(strings.TrimSpace(trashRetention.Text))
+			if err != nil || retention < 1 {
+				dialog.ShowError(errors.New(fmt.Sprintf("invalid number of days %s", trashRetention.Text)), mainWindow)
+				return
+			}
+			roots, err := parseLibraryRoots(libraryRoots.Text)
+			if err != nil {
+				dialog.ShowError(err, mainWindow)
+				return
+			}
+			if err = setLibraryRoots(roots); err != nil {
+				dialog.ShowError(err, mainWindow)
+				return
+			}
+			updateConfig(func(cfg settings.Settings) settings.Settings {
+				cfg.Config.BandwidthLimit = limit
+				cfg.Config.ProviderBandwidthLimits = providerLimits
+				cfg.Config.DownloadWindows = downloadWindows
+				cfg.Config.UpdateCheckInterval = interval
+				cfg.Config.AutoDownload = autoDownload.Checked
+				cfg.Config.AutoDownloadLimit = downloadLimit
+				cfg.Config.TrashRetention = retention
+				settings.WriteSettings(cfg)
+				return cfg
+			})
+			resetUpdateChecker()
+			dialog.ShowInformation("Preferences", "Your preferences have been saved.", mainWindow)
+		},
+		SubmitText: "Save preferences",
+	}
+
+	history := widget.NewButtonWithIcon("Show downloads history...", theme.HistoryIcon(), func() {
+		showDownloadsHistory()
+	})
+
+	comicInfo := widget.NewButtonWithIcon("Add ComicInfo.xml to downloaded chapters...", theme.DocumentIcon(), func() {
+		go func() {
+			nbUpdated, err := BackfillComicInfo(false)
+			if err != nil {
+				dialog.ShowError(err, mainWindow)
+				return
+			}
+			dialog.ShowInformation("ComicInfo.xml", fmt.Sprintf("%d chapters have been updated.", nbUpdated), mainWindow)
+		}()
+	})
+
+	verify := widget.NewButtonWithIcon("Verify library...", theme.ConfirmIcon(), func() {
+		go showVerifyReport()
+	})
+
+	rebuild := widget.NewButtonWithIcon("Rebuild history from the library...", theme.FolderOpenIcon(), func() {
+		message := "The titles described by the series.json files of the library will replace the ones of your history.\nDo you want to continue?"
+		dialog.ShowConfirm("Rebuild history", message, func(ok bool) {
+			if !ok {
+				return
+			}
+			nbTitles, err := rebuildHistory()
+			if err != nil {
+				dialog.ShowError(err, mainWindow)
+			}
+			reloadLibrary(4)
+			dialog.ShowInformation("Rebuild history", fmt.Sprintf("%d titles have been read from the library.", nbTitles), mainWindow)
+		}, mainWindow)
+	})
+
+	scan := widget.NewButtonWithIcon("Scan library folder...", theme.SearchIcon(), func() {
+		go showScanReport()
+	})
+
+	move := widget.NewButtonWithIcon("Move library...", theme.FolderIcon(), func() {
+		showMoveLibraryDialog()
+	})
+
+	trash := widget.NewButtonWithIcon("Restore removed series...", theme.ContentUndoIcon(), func() {
+		showTrashDialog()
+	})
+
+	objects := append(actions, history, comicInfo, verify, scan, rebuild, move, trash, form, newLayoutForm())
+	return container.NewVBox(objects...)
+}
+
+/*
+newLayoutForm build the form used to choose the naming profile of the library. since the archives
+must follow the profile, changing it always renames the files already downloaded.
+*/
+func newLayoutForm() fyne.CanvasObject {
+	current := currentConfig().Config.Naming()
+
+	folderTemplate := widget.NewEntry()
+	folderTemplate.SetText(current.Folder)
+	fileTemplate := widget.NewEntry()
+	fileTemplate.SetText(current.File)
+
+	var names []string
+	for _, profile := range settings.NamingProfiles {
+		names = append(names, profile.Name)
+	}
+	names = append(names, settings.CustomNamingProfile)
+	profiles := widget.NewSelect(names, func(name string) {
+		for _, profile := range settings.NamingProfiles {
+			if profile.Name == name {
+				folderTemplate.SetText(profile.Folder)
+				fileTemplate.SetText(profile.File)
+				folderTemplate.Disable()
+				fileTemplate.Disable()
+				return
+			}
+		}
+		folderTemplate.Enable()
+		fileTemplate.Enable()
+	})
+	profiles.SetSelected(current.Name)
+
+	return &widget.Form{
+		Items: []*widget.FormItem{
+			{Text: "Library layout", Widget: profiles},
+			{Text: "Series folder template", Widget: folderTemplate},
+			{Text: "Chapter file template", Widget: fileTemplate},
+		},
+		OnSubmit: func() {
+			profile := settings.NamingProfile{Name: profiles.Selected, Folder: strings.TrimSpace(folderTemplate.Text), File: strings.TrimSpace(fileTemplate.Text)}
+			if profile.Folder == "" || profile.File == "" || !strings.Contains(profile.File, "{chapter") {
+				dialog.ShowError(errors.New("the templates can not be empty, and the chapter file template must use the chapter"), mainWindow)
+				return
+			}
+			message := fmt.Sprintf("All the chapters of the library will be renamed using the %s layout.\nDo you want to continue?", profile.Name)
+			dialog.ShowConfirm("Library layout", message, func(ok bool) {
+				if !ok {
+					return
+				}
+				resume := suspendWatcher()
+				closeReader("")
+				// the archives are renamed without holding the settings, then the new layout is applied to the current settings
+				renamed, err := settings.RenameLibrary(currentConfig(), profile)
+				if err == nil {
+					updateConfig(func(cfg settings.Settings) settings.Settings {
+						return applyLayout(cfg, renamed)
+					})
+				}
+				resume()
+				if err != nil {
+					dialog.ShowError(err, mainWindow)
+				} else {
+					dialog.ShowInformation("Library layout", "The library has been renamed.", mainWindow)
+				}
+				if library != nil {
+					for _, tb := range library.Items {
+						if manga, found := findManga(tb.Title.Title); found {
+							tb.Title.Path, tb.Title.ChapterFiles = manga.Path, manga.ChapterFiles
+						}
+					}
+				}
+				if series != nil {
+					if manga, found := findManga(series.SelectedManga.Title); found {
+						series.SelectedManga.Path, series.SelectedManga.ChapterFiles = manga.Path, manga.ChapterFiles
+					}
+				}
+				if chapters != nil {
+					chapters.Refresh()
+				}
+			}, mainWindow)
+		},
+		SubmitText: "Rename library to this layout",
+	}
+}
+
+// showDownloadsHistory display the downloads log, most recent first
+func showDownloadsHistory() {
+	downloads := currentConfig().History.Downloads
+	list := widget.NewList(
+		func() int {
+			return len(downloads)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			record := downloads[len(downloads)-1-id]
+			origin := "manual"
+			if record.Automatic {
+				origin = "automatic"
+			}
+			item.(*widget.Label).SetText(fmt.Sprintf("%s - %s chapter %03.1f (%s)", record.Date.Format("2006-01-02 15:04"), record.Name, record.Chapter, origin))
+		},
+	)
+	content := container.NewScroll(list)
+	content.SetMinSize(fyne.NewSize(currentConfig().Config.PageWidth, currentConfig().Config.ThumbnailHeight*2))
+	dialog.ShowCustom(fmt.Sprintf("Downloads history (%d queued)", queue.Len()), "Close", content, mainWindow)
+}
+
+/*
+showVerifyReport verifies the library and displays the report. when broken archives are found, the user
+can move them to the quarantine and download them again.
+*/
+func showVerifyReport() {
+	report := verifyLibrary()
+	label := widget.NewLabel(report.String())
+	label.Wrapping = fyne.TextWrapWord
+	content := container.NewScroll(label)
+	content.SetMinSize(fyne.NewSize(currentConfig().Config.PageWidth, currentConfig().Config.ThumbnailHeight*2))
+	if len(report.Problems) == 0 {
+		dialog.ShowCustom("Library verification", "Close", content, mainWindow)
+		return
+	}
+	dialog.ShowCustomConfirm("Library verification", "Repair", "Close", content, func(repair bool) {
+		if !repair {
+			return
+		}
+		nbQueued, err := repairLibrary(report)
+		if err != nil {
+			dialog.ShowError(err, mainWindow)
+		}
+		if chapters != nil {
+			chapters.Refresh()
+		}
+		dialog.ShowInformation("Library verification", fmt.Sprintf("%d broken archives moved to the quarantine,\n%d chapters queued for download.", len(report.Problems), nbQueued), mainWindow)
+	}, mainWindow)
+}
+
+// showScanReport scans the library folder, and offers to fix the history and the library
+func showScanReport() {
+	report := scanLibrary()
+	label := widget.NewLabel(report.String())
+	label.Wrapping = fyne.TextWrapWord
+	content := container.NewScroll(label)
+	content.SetMinSize(fyne.NewSize(currentConfig().Config.PageWidth, currentConfig().Config.ThumbnailHeight*2))
+	if len(report.Missing) == 0 && len(report.Unknown) == 0 && len(report.Orphaned) == 0 {
+		dialog.ShowCustom("Library scan", "Close", content, mainWindow)
+		return
+	}
+	dialog.ShowCustomConfirm("Library scan", "Fix", "Close", content, func(fix bool) {
+		if !fix {
+			return
+		}
+		nbAdopted, nbRemoved, err := applyScan(report)
+		if err != nil {
+			dialog.ShowError(err, mainWindow)
+		}
+		reloadLibrary(4)
+		dialog.ShowInformation("Library scan", fmt.Sprintf("%d chapters forgotten, %d archives adopted,\n%d metadata files removed.", len(report.Missing), nbAdopted, nbRemoved), mainWindow)
+	}, mainWindow)
+}
+
+// formatProviderLimits returns the limits as a "provider=rate, provider=rate" list
+func formatProviderLimits(limits map[string]int) string {
+	var providers []string
+	for p := range limits {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+	var values []string
+	for _, p := range providers {
+		values = append(values, fmt.Sprintf("%s=%d", p, limits[p]))
+	}
+	return strings.Join(values, ", ")
+}
+
+// parseProviderLimits reads a "provider=rate, provider=rate" list
+func parseProviderLimits(value string) (map[string]int, error) {
+	limits := map[string]int{}
+	for _, v := range strings.Split(value, ",") {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		fields := strings.Split(v, "=")
+		if len(fields) != 2 {
+			return nil, errors.New(fmt.Sprintf("invalid provider limit %s, expected provider=rate", strings.TrimSpace(v)))
+		}
+		rate, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil || rate < 0 {
+			return nil, errors.New(fmt.Sprintf("invalid rate for provider %s", strings.TrimSpace(fields[0])))
+		}
+		limits[strings.TrimSpace(fields[0])] = rate
+	}
+	return limits, nil
+}
@@ -0,0 +1,317 @@
+package widget
+
+import (
+	"errors"
+	"fmt"
+	"github.com/francoiscolombo/gomangareader/settings"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+downloadQueue keep the chapters waiting to be downloaded. they are processed one after the other in
+the background, and only during the download windows. the queue is saved after each change, so the
+chapters left when the application is closed are downloaded at the next start.
+*/
+type downloadQueue struct {
+	mu      sync.Mutex
+	pending []settings.QueuedChapter
+	running bool
+	// current is the chapter being downloaded, nil if none
+	current *settings.QueuedChapter
+}
+
+var queue = &downloadQueue{}
+
+/*
+Enqueue adds a chapter to the queue, automatic tells if it was queued by the update checker.
+it returns false if this chapter is already waiting.
+*/
+func (q *downloadQueue) Enqueue(title string, chapter float64, automatic bool) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, item := range q.pending {
+		if item.Title == title && item.Chapter == chapter {
+			return false
+		}
+	}
+	q.pending = append(q.pending, settings.QueuedChapter{Title: title, Chapter: chapter, Automatic: automatic})
+	q.save()
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+	return true
+}
+
+// Drop removes from the queue the chapters of a manga, the chapter being downloaded is not saved
+func (q *downloadQueue) Drop(title string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var pending []settings.QueuedChapter
+	for _, item := range q.pending {
+		if item.Title != title {
+			pending = append(pending, item)
+		}
+	}
+	q.pending = pending
+	q.save()
+}
+
+// save writes the chapter being downloaded and the chapters waiting, the caller holds q.mu
+func (q *downloadQueue) save() {
+	var chapters []settings.QueuedChapter
+	if q.current != nil {
+		chapters = append(chapters, *q.current)
+	}
+	chapters = append(chapters, q.pending...)
+	if err := settings.WriteQueue(chapters); err != nil {
+		log.Printf("Error when trying to save the download queue: %s", err)
+	}
+}
+
+// restoreQueue queues again the chapters left in the queue by the last session
+func restoreQueue() {
+	chapters, err := settings.ReadQueue()
+	if err != nil {
+		log.Printf("Error when trying to read the download queue: %s", err)
+		return
+	}
+	nbQueued := 0
+	for _, item := range chapters {
+		if _, found := findManga(item.Title); found && queue.Enqueue(item.Title, item.Chapter, item.Automatic) {
+			nbQueued++
+		}
+	}
+	if nbQueued > 0 {
+		log.Printf("%d chapters of the last session queued again", nbQueued)
+	}
+}
+
+// Len returns the number of chapters waiting in the queue
+func (q *downloadQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Contains tells if a chapter is waiting in the queue or being downloaded
+func (q *downloadQueue) Contains(title string, chapter float64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.current != nil && q.current.Title == title && q.current.Chapter == chapter {
+		return true
+	}
+	for _, item := range q.pending {
+		if item.Title == title && item.Chapter == chapter {
+			return true
+		}
+	}
+	return false
+}
+
+// Wait blocks until all the queued chapters have been processed
+func (q *downloadQueue) Wait() {
+	for {
+		q.mu.Lock()
+		running := q.running
+		q.mu.Unlock()
+		if !running {
+			return
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func (q *downloadQueue) run() {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.running = false
+			q.current = nil
+			q.save()
+			q.mu.Unlock()
+			return
+		}
+		item := q.pending[0]
+		q.pending = q.pending[1:]
+		q.current = &item
+		q.save()
+		q.mu.Unlock()
+		waitForDownloadWindow()
+		err := downloadQueuedChapter(item)
+		if err != nil {
+			log.Printf("Error when trying to download queued chapter %03.1f of %s, error is %s", item.Chapter, item.Title, err)
+		}
+	}
+}
+
+func downloadQueuedChapter(item settings.QueuedChapter) error {
+	manga, ok := findManga(item.Title)
+	if !ok {
+		return errors.New(fmt.Sprintf("%s is not in the library anymore", item.Title))
+	}
+	if _, _, err := downloadChapter(manga, item.Chapter, true, nil); err != nil {
+		return err
+	}
+	// the manga is read again, it may have been changed or removed during the download
+	manga, ok = updateManga(item.Title, func(manga *settings.Manga) {
+		// same bookkeeping than the downloader: last chapter moves to the next one to download
+		if item.Chapter >= manga.LastChapter {
+			for _, c := range manga.Chapters {
+				if c > item.Chapter {
+					manga.LastChapter = c
+					break
+				}
+			}
+		}
+	})
+	if !ok {
+		return errors.New(fmt.Sprintf("%s was removed from the library during the download", item.Title))
+	}
+	recordDownload(settings.DownloadRecord{
+		Title:     manga.Title,
+		Name:      manga.Name,
+		Chapter:   item.Chapter,
+		Date:      time.Now(),
+		Automatic: item.Automatic,
+	})
+	extractFirstPages(manga)
+	if chapters != nil && chapters.Title == manga.Title {
+		chapters.Refresh()
+	}
+	return nil
+}
+
+/*
+queueMissingChapters put in the queue the chapters of a manga, starting from its last chapter, that are
+not downloaded yet. at most limit chapters are queued (no limit if limit is 0 or less), and the number of
+chapters queued is returned.
+*/
+func queueMissingChapters(manga settings.Manga, limit int, automatic bool) int {
+	nbQueued := 0
+	if !currentConfig().Config.RootAvailable(manga.Root) {
+		// the archives can not be seen, the chapters would be downloaded again
+		return nbQueued
+	}
+	for _, c := range manga.Chapters {
+		if limit > 0 && nbQueued >= limit {
+			break
+		}
+		if c < manga.LastChapter {
+			continue
+		}
+		if _, err := os.Stat(chapterArchive(manga, c)); err == nil {
+			continue
+		}
+		if queue.Enqueue(manga.Title, c, automatic) {
+			nbQueued++
+		}
+	}
+	return nbQueued
+}
+
+// parseClock converts a "HH:MM" time to a number of minutes since midnight
+func parseClock(value string) (int, error) {
+	fields := strings.Split(strings.TrimSpace(value), ":")
+	if len(fields) != 2 {
+		return 0, errors.New(fmt.Sprintf("invalid time %s, expected HH:MM", value))
+	}
+	h, err1 := strconv.Atoi(fields[0])
+	m, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, errors.New(fmt.Sprintf("invalid time %s, expected HH:MM", value))
+	}
+	return h*60 + m, nil
+}
+
+/*
+inDownloadWindow tells if the queued downloads can run at the given time. without any valid window,
+downloads are always allowed. a window with the same start and end covers the whole day.
+*/
+func inDownloadWindow(windows []settings.DownloadWindow, now time.Time) bool {
+	minutes := now.Hour()*60 + now.Minute()
+	valid := false
+	for _, w := range windows {
+		start, err1 := parseClock(w.Start)
+		end, err2 := parseClock(w.End)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		valid = true
+		if start == end {
+			return true
+		}
+		if start < end && minutes >= start && minutes < end {
+			return true
+		}
+		if start > end && (minutes >= start || minutes < end) {
+			return true
+		}
+	}
+	return !valid
+}
+
+// nextDownloadWindow returns the time when the next download window opens
+func nextDownloadWindow(windows []settings.DownloadWindow, now time.Time) time.Time {
+	var next time.Time
+	for _, w := range windows {
+		start, err := parseClock(w.Start)
+		if err != nil {
+			continue
+		}
+		candidate := time.Date(now.Year(), now.Month(), now.Day(), start/60, start%60, 0, 0, now.Location())
+		if !candidate.After(now) {
+			candidate = candidate.AddDate(0, 0, 1)
+		}
+		if next.IsZero() || candidate.Before(next) {
+			next = candidate
+		}
+	}
+	return next
+}
+
+// waitForDownloadWindow blocks until the downloads are allowed. the windows are checked again every minute
+func waitForDownloadWindow() {
+	for windows := currentConfig().Config.DownloadWindows; !inDownloadWindow(windows, time.Now()); windows = currentConfig().Config.DownloadWindows {
+		delay := time.Until(nextDownloadWindow(windows, time.Now()))
+		if delay <= 0 || delay > time.Minute {
+			delay = time.Minute
+		}
+		time.Sleep(delay)
+	}
+}
+
+// formatDownloadWindows returns the windows as a "01:00-07:00, 13:00-14:00" list
+func formatDownloadWindows(windows []settings.DownloadWindow) string {
+	var ranges []string
+	for _, w := range windows {
+		ranges = append(ranges, fmt.Sprintf("%s-%s", w.Start, w.End))
+	}
+	return strings.Join(ranges, ", ")
+}
+
+// parseDownloadWindows reads a "01:00-07:00, 13:00-14:00" list
+func parseDownloadWindows(value string) ([]settings.DownloadWindow, error) {
+	var windows []settings.DownloadWindow
+	for _, r := range strings.Split(value, ",") {
+		if strings.TrimSpace(r) == "" {
+			continue
+		}
+		bounds := strings.Split(r, "-")
+		if len(bounds) != 2 {
+			return nil, errors.New(fmt.Sprintf("invalid window %s, expected HH:MM-HH:MM", strings.TrimSpace(r)))
+		}
+		for _, b := range bounds {
+			if _, err := parseClock(b); err != nil {
+				return nil, err
+			}
+		}
+		windows = append(windows, settings.DownloadWindow{Start: strings.TrimSpace(bounds[0]), End: strings.TrimSpace(bounds[1])})
+	}
+	return windows, nil
+}
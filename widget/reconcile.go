@@ -0,0 +1,347 @@
+package widget
+
+import (
+	"errors"
+	"fmt"
+	"github.com/francoiscolombo/gomangareader/archive"
+	"github.com/francoiscolombo/gomangareader/settings"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// chapterNumberPatterns find the chapter in the name of an archive, the first pattern matching is used
+var chapterNumberPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(?:chapter|ch\.?|c)\s*(\d+(?:\.\d+)?)`),
+	regexp.MustCompile(`(\d+(?:\.\d+)?)\D*$`),
+}
+
+// libraryFile is an archive of the library that does not match the history
+type libraryFile struct {
+	Path    string
+	Title   string
+	Name    string
+	Chapter float64
+}
+
+// reconcileReport is the result of the comparison of the library folder with the history
+type reconcileReport struct {
+	// Checked is the number of archives found in the library
+	Checked int
+	// Missing are the chapters downloaded according to the history, without an archive
+	Missing []libraryFile
+	// Unknown are the archives that are not in the history, the ones recognized have a title
+	Unknown []libraryFile
+	// Orphaned are the covers and thumbnails of the mangas and chapters that are not in the history anymore
+	Orphaned []string
+}
+
+// String returns the report as displayed to the user
+func (report reconcileReport) String() string {
+	lines := []string{fmt.Sprintf("%d archives found, %d missing, %d unknown, %d orphaned metadata files.",
+		report.Checked, len(report.Missing), len(report.Unknown), len(report.Orphaned))}
+	for _, f := range report.Missing {
+		lines = append(lines, fmt.Sprintf("missing: %s chapter %03.1f (%s)", f.Name, f.Chapter, f.Path))
+	}
+	for _, f := range report.Unknown {
+		if f.Title != "" {
+			lines = append(lines, fmt.Sprintf("unknown: %s, recognized as %s chapter %03.1f", f.Path, f.Name, f.Chapter))
+		} else {
+			lines = append(lines, fmt.Sprintf("unknown: %s, not recognized", f.Path))
+		}
+	}
+	for _, path := range report.Orphaned {
+		lines = append(lines, fmt.Sprintf("orphaned: %s", path))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isDownloaded tells if the history records a chapter as downloaded
+func isDownloaded(manga settings.Manga, chapter float64) bool {
+	if _, ok := manga.PageCounts[settings.ChapterKey(chapter)]; ok {
+		return true
+	}
+	return chapter < manga.LastChapter
+}
+
+/*
+scanLibrary compares the library folder with the history: the chapters downloaded without an archive,
+the archives unknown from the history, and the metadata files that are not used anymore. the hidden
+folders of the library, like the quarantine, are not scanned, and neither are the offline roots: their
+chapters are not missing, only unavailable. a root that can not be listed is handled as offline, so an
+archive that can not be read is never taken for a missing one.
+*/
+func scanLibrary() reconcileReport {
+	cfg := currentConfig()
+	var report reconcileReport
+	known := map[string]bool{}
+	// listable tells for each root of the history if its folder can be listed
+	listable := map[string]bool{}
+	used := map[string]bool{filepath.Join(cfg.Config.LibraryPath, ".metadata", thumbnailsMarker): true}
+	for _, manga := range cfg.History.Titles {
+		for _, path := range []string{manga.CoverPath, coverThumbnailPath(manga), fallbackCoverPath(manga), manga.CustomCover} {
+			used[filepath.Clean(path)] = true
+		}
+		available, checked := listable[manga.Root]
+		if !checked {
+			available = cfg.Config.RootAvailable(manga.Root) && rootListable(cfg.Config.RootPath(manga.Root))
+			listable[manga.Root] = available
+		}
+		for _, chapter := range manga.Chapters {
+			cbzArchive := filepath.Clean(chapterArchive(manga, chapter))
+			known[cbzArchive] = true
+			used[filepath.Clean(settings.ThumbnailPath(cfg.Config, manga, chapter))] = true
+			if !available {
+				continue
+			}
+			if _, err := os.Stat(cbzArchive); os.IsNotExist(err) && isDownloaded(manga, chapter) {
+				report.Missing = append(report.Missing, libraryFile{Path: cbzArchive, Title: manga.Title, Name: manga.Name, Chapter: chapter})
+			}
+		}
+	}
+
+	for _, root := range cfg.Config.LibraryRoots() {
+		if cfg.Config.RootAvailable(root.Name) {
+			scanRoot(root.Path, known, &report)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(cfg.Config.LibraryPath, ".metadata"))
+	if err == nil {
+		for _, entry := range entries {
+			path := filepath.Join(cfg.Config.LibraryPath, ".metadata", entry.Name())
+			if !entry.IsDir() && !used[path] {
+				report.Orphaned = append(report.Orphaned, path)
+			}
+		}
+	}
+	log.Printf("Library scanned, %d archives found, %d missing, %d unknown, %d orphaned metadata files",
+		report.Checked, len(report.Missing), len(report.Unknown), len(report.Orphaned))
+	return report
+}
+
+// scanRoot looks for the archives of a library root that are not known from the history
+func scanRoot(rootPath string, known map[string]bool, report *reconcileReport) {
+	err := filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("Error when trying to scan %s: %s", path, err)
+			return nil
+		}
+		if d.IsDir() {
+			if path != rootPath && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".cbz") {
+			return nil
+		}
+		report.Checked++
+		if known[filepath.Clean(path)] {
+			return nil
+		}
+		unknown := libraryFile{Path: path}
+		if manga, chapter, ok := identifyArchive(path); ok {
+			unknown.Title, unknown.Name, unknown.Chapter = manga.Title, manga.Name, chapter
+		}
+		report.Unknown = append(report.Unknown, unknown)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error when trying to scan %s: %s", rootPath, err)
+	}
+}
+
+/*
+identifyArchive finds the manga and the chapter of an archive unknown from the history. the manga is
+found from the folder of the archive or from its metadata, and the chapter from its metadata or from
+its name.
+*/
+func identifyArchive(cbzArchive string) (settings.Manga, float64, bool) {
+	cfg := currentConfig()
+	meta, _ := archive.ReadMetadata(cbzArchive)
+	folder := filepath.Dir(cbzArchive)
+	var manga settings.Manga
+	found := false
+	for _, m := range cfg.History.Titles {
+		if filepath.Clean(settings.SeriesPath(cfg.Config, m)) == folder {
+			manga, found = m, true
+			break
+		}
+	}
+	for _, name := range []string{meta.Series, filepath.Base(folder)} {
+		if found || name == "" {
+			break
+		}
+		for _, m := range cfg.History.Titles {
+			if strings.EqualFold(m.Name, name) || strings.EqualFold(m.Title, name) {
+				manga, found = m, true
+				break
+			}
+		}
+	}
+	if !found {
+		return manga, 0, false
+	}
+	if chapter, err := strconv.ParseFloat(strings.TrimSpace(meta.Number), 64); err == nil {
+		return manga, chapter, true
+	}
+	name := strings.TrimSuffix(filepath.Base(cbzArchive), filepath.Ext(cbzArchive))
+	// the name of the series may contain numbers
+	for _, series := range []string{manga.Name, manga.Title} {
+		if series != "" && len(name) > len(series) && strings.EqualFold(name[:len(series)], series) {
+			name = name[len(series):]
+			break
+		}
+	}
+	for _, pattern := range chapterNumberPatterns {
+		if match := pattern.FindStringSubmatch(name); match != nil {
+			if chapter, err := strconv.ParseFloat(match[1], 64); err == nil {
+				return manga, chapter, true
+			}
+		}
+	}
+	return manga, 0, false
+}
+
+/*
+adoptArchive moves an archive recognized by the scan to its place in the library, and adds its chapter
+to the history of the manga.
+*/
+func adoptArchive(f libraryFile) error {
+	manga, ok := findManga(f.Title)
+	if !ok {
+		return errors.New(fmt.Sprintf("%s is not in the library anymore", f.Title))
+	}
+	target := chapterArchive(manga, f.Chapter)
+	if filepath.Clean(target) != filepath.Clean(f.Path) {
+		if _, err := os.Stat(target); err == nil {
+			return errors.New(fmt.Sprintf("%s can not be adopted, %s already exists", f.Path, target))
+		}
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.Rename(f.Path, target); err != nil {
+			return err
+		}
+	}
+	nbPages, err := archive.Verify(target)
+	if err != nil {
+		return err
+	}
+	meta, metaErr := archive.ReadMetadata(target)
+	updateManga(f.Title, func(manga *settings.Manga) {
+		hasChapter := false
+		for _, c := range manga.Chapters {
+			if c == f.Chapter {
+				hasChapter = true
+				break
+			}
+		}
+		if !hasChapter {
+			manga.Chapters = append(append([]float64{}, manga.Chapters...), f.Chapter)
+			sort.Float64s(manga.Chapters)
+		}
+		manga.SetPageCount(f.Chapter, nbPages)
+		manga.SetChapterFile(f.Chapter, target)
+		if metaErr == nil {
+			applyMetadata(manga, f.Chapter, meta)
+		}
+	})
+	log.Printf("%s adopted as %s chapter %03.1f", f.Path, manga.Title, f.Chapter)
+	return nil
+}
+
+// rootListable tells if the folder of a library root can be listed
+func rootListable(rootPath string) bool {
+	_, err := os.ReadDir(rootPath)
+	if err != nil {
+		log.Printf("The library root %s can not be listed, its chapters are not checked: %s", rootPath, err)
+	}
+	return err == nil
+}
+
+/*
+forgetChapter removes a chapter without an archive from the downloaded chapters of the history. the
+archive is checked again, it may have come back since the scan, and it tells if the chapter is forgotten.
+*/
+func forgetChapter(f libraryFile) bool {
+	if _, err := os.Stat(f.Path); !os.IsNotExist(err) {
+		return false
+	}
+	_, found := updateManga(f.Title, func(manga *settings.Manga) {
+		counts := map[string]int{}
+		for k, v := range manga.PageCounts {
+			if k != settings.ChapterKey(f.Chapter) {
+				counts[k] = v
+			}
+		}
+		manga.PageCounts = counts
+		// the chapter is offered for download again
+		if f.Chapter < manga.LastChapter {
+			manga.LastChapter = f.Chapter
+		}
+	})
+	return found
+}
+
+/*
+applyScan fixes the history and the library from a scan report: the missing chapters are not recorded
+as downloaded anymore, the recognized archives are adopted, and the orphaned metadata files are removed.
+it returns the number of archives adopted and of files removed.
+*/
+func applyScan(report reconcileReport) (nbAdopted, nbRemoved int, err error) {
+	var failures []string
+	for _, f := range report.Missing {
+		forgetChapter(f)
+	}
+	adopted := map[string]bool{}
+	for _, f := range report.Unknown {
+		if f.Title == "" {
+			continue
+		}
+		if e := adoptArchive(f); e != nil {
+			failures = append(failures, e.Error())
+			continue
+		}
+		adopted[f.Title] = true
+		nbAdopted++
+	}
+	for title := range adopted {
+		if manga, ok := findManga(title); ok {
+			extractFirstPages(manga)
+		}
+	}
+	for _, path := range report.Orphaned {
+		if e := os.Remove(path); e != nil {
+			failures = append(failures, e.Error())
+			continue
+		}
+		nbRemoved++
+	}
+	if len(failures) > 0 {
+		err = errors.New(fmt.Sprintf("%d files could not be fixed:\n%s", len(failures), strings.Join(failures, "\n")))
+	}
+	return nbAdopted, nbRemoved, err
+}
+
+/*
+ScanLibrary compares the library folder with the history and returns the report. when apply is true,
+the history and the library are fixed before returning.
+*/
+func ScanLibrary(apply bool) (string, error) {
+	loadConfig()
+	report := scanLibrary()
+	if !apply {
+		return report.String(), nil
+	}
+	nbAdopted, nbRemoved, err := applyScan(report)
+	waitForThumbnails()
+	return fmt.Sprintf("%s\n%d chapters forgotten, %d archives adopted, %d metadata files removed.",
+		report, len(report.Missing), nbAdopted, nbRemoved), err
+}
@@ -0,0 +1,163 @@
+package widget
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/francoiscolombo/gomangareader/settings"
+	"log"
+	"path/filepath"
+)
+
+// metadataFiles returns the covers and thumbnails of a manga, only the files of the metadata folder are returned
+func metadataFiles(manga settings.Manga) []string {
+	metadataPath := filepath.Join(currentConfig().Config.LibraryPath, ".metadata")
+	var files []string
+	candidates := []string{manga.CoverPath, coverThumbnailPath(manga), fallbackCoverPath(manga), manga.CustomCover}
+	for _, chapter := range manga.Chapters {
+		candidates = append(candidates, settings.ThumbnailPath(currentConfig().Config, manga, chapter))
+	}
+	for _, file := range candidates {
+		if file != "" && filepath.Dir(filepath.Clean(file)) == metadataPath {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+/*
+removeSeries removes a manga from the library, and its files when deleteFiles is true. the library
+displays the first title left when the manga removed was displayed.
+*/
+func removeSeries(manga *settings.Manga, deleteFiles bool) (settings.TrashEntry, error) {
+	resume := suspendWatcher()
+	defer resume()
+	queue.Drop(manga.Title)
+	closeReader(manga.Title)
+	// the chapter being downloaded is written before the removal, or not at all, see downloadChapter
+	unlock := lockSeries(manga.Title)
+	defer unlock()
+	// the files are moved to the trash without holding the settings, then the manga is removed from the current settings
+	_, entry, err := settings.RemoveSeries(currentConfig(), *manga, metadataFiles(*manga), deleteFiles)
+	if err != nil {
+		return entry, err
+	}
+	updateConfig(func(cfg settings.Settings) settings.Settings {
+		return settings.RemoveFromHistory(cfg, manga.Title)
+	})
+	if library == nil {
+		return entry, nil
+	}
+	for _, tb := range library.Items {
+		if tb.Title.Title == manga.Title {
+			library.Remove(tb)
+			break
+		}
+	}
+	if len(library.Items) > 0 && (library.Manga == nil || library.Manga.Title == manga.Title) {
+		library.Items[0].Selected = true
+		library.Manga = library.Items[0].Title
+		refreshTabsContent(library.Manga, 0)
+	}
+	return entry, nil
+}
+
+// restoreSeries puts a removed manga back in the library, and displays it
+func restoreSeries(entry settings.TrashEntry) error {
+	resume := suspendWatcher()
+	defer resume()
+	// the files are restored without holding the settings, then the manga is added to the current settings
+	restored, manga, err := settings.RestoreSeries(currentConfig(), entry)
+	if _, found := findMangaIn(restored, manga.Title); !found || !addManga(manga) {
+		return err
+	}
+	if e := ensureCover(manga); e != nil {
+		log.Printf("Error when trying to get a cover for %s: %s", manga.Title, e)
+	}
+	extractFirstPages(manga)
+	if library != nil {
+		tb := NewTitleButton(manga)
+		for _, item := range library.Items {
+			item.Selected = false
+		}
+		tb.Selected = true
+		library.Add(tb)
+		refreshTabsContent(tb.Title, 0)
+	}
+	return err
+}
+
+/*
+showRemoveSeriesDialog asks the user to confirm the removal of a manga, and if its files must be removed
+too. once removed, the manga can be restored right away, or later from the preferences.
+*/
+func showRemoveSeriesDialog(manga *settings.Manga) {
+	deleteFiles := widget.NewCheck("Also move its chapters, thumbnails and cover to the trash", nil)
+	message := widget.NewLabel(fmt.Sprintf("%s will be removed from your library.", manga.Name))
+	content := container.NewVBox(message, deleteFiles)
+	dialog.ShowCustomConfirm("Remove series", "Remove", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		entry, err := removeSeries(manga, deleteFiles.Checked)
+		if err != nil {
+			dialog.ShowError(err, mainWindow)
+			return
+		}
+		undo := widget.NewLabel(fmt.Sprintf("%s has been removed. It can be restored from the preferences during %d days.", entry.Manga.Name, currentConfig().Config.TrashRetentionDays()))
+		undo.Wrapping = fyne.TextWrapWord
+		dialog.ShowCustomConfirm("Remove series", "Undo", "Close", undo, func(restore bool) {
+			if !restore {
+				return
+			}
+			if err := restoreSeries(entry); err != nil {
+				dialog.ShowError(err, mainWindow)
+			}
+		}, mainWindow)
+	}, mainWindow)
+}
+
+// showTrashDialog displays the mangas of the trash, the one selected can be restored
+func showTrashDialog() {
+	entries := settings.ReadTrash(currentConfig().Config)
+	if len(entries) == 0 {
+		dialog.ShowInformation("Removed series", "There is no removed series in the trash.", mainWindow)
+		return
+	}
+	var trash dialog.Dialog
+	list := widget.NewList(
+		func() int {
+			return len(entries)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			entry := entries[id]
+			files := "history only"
+			if entry.Folder {
+				files = "with its files"
+			}
+			item.(*widget.Label).SetText(fmt.Sprintf("%s - %s (%s)", entry.Date.Format("2006-01-02 15:04"), entry.Manga.Name, files))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		entry := entries[id]
+		dialog.ShowConfirm("Restore series", fmt.Sprintf("Do you want to restore %s in your library?", entry.Manga.Name), func(ok bool) {
+			list.UnselectAll()
+			if !ok {
+				return
+			}
+			trash.Hide()
+			if err := restoreSeries(entry); err != nil {
+				dialog.ShowError(err, mainWindow)
+			}
+		}, mainWindow)
+	}
+	content := container.NewScroll(list)
+	content.SetMinSize(fyne.NewSize(currentConfig().Config.PageWidth, currentConfig().Config.ThumbnailHeight*2))
+	trash = dialog.NewCustom(fmt.Sprintf("Removed series (kept %d days)", currentConfig().Config.TrashRetentionDays()), "Close", content, mainWindow)
+	trash.Show()
+}
@@ -0,0 +1,227 @@
+package widget
+
+import (
+	"errors"
+	"fmt"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/francoiscolombo/gomangareader/settings"
+	"strings"
+	"sync"
+	"time"
+)
+
+// libraryRoot is the name of the library root whose titles are displayed in the library, empty for all
+var libraryRoot string
+
+// rootsCheckInterval is the delay between two checks of the availability of the library roots
+const rootsCheckInterval = time.Minute
+
+/*
+rootsAvailable caches the availability of the library roots, keyed by root name, so the widgets never
+wait for a network share that does not answer. rootsChecking tells if a check is running.
+*/
+var rootsAvailable = map[string]bool{}
+var rootsChecking bool
+var rootsLock sync.Mutex
+
+// rootAvailable returns the availability of a library root found by the last check, a root not checked yet is available
+func rootAvailable(root string) bool {
+	rootsLock.Lock()
+	defer rootsLock.Unlock()
+	available, checked := rootsAvailable[root]
+	return available || !checked
+}
+
+/*
+checkLibraryRoots checks the availability of the library roots in the background, and refreshes the
+library when it changed. a check is not started while the previous one is still waiting for a root.
+*/
+func checkLibraryRoots() {
+	rootsLock.Lock()
+	if rootsChecking {
+		rootsLock.Unlock()
+		return
+	}
+	rootsChecking = true
+	rootsLock.Unlock()
+	go func() {
+		cfg := currentConfig().Config
+		available := map[string]bool{}
+		for _, root := range cfg.LibraryRoots() {
+			name := root.Name
+			if name == settings.MainRoot {
+				name = ""
+			}
+			available[name] = cfg.RootAvailable(name)
+		}
+		rootsLock.Lock()
+		changed := len(available) != len(rootsAvailable)
+		for name, value := range available {
+			if previous, found := rootsAvailable[name]; !found || previous != value {
+				changed = true
+			}
+		}
+		rootsAvailable = available
+		rootsChecking = false
+		rootsLock.Unlock()
+		if !changed {
+			return
+		}
+		if library != nil {
+			library.Refresh()
+		}
+		if series != nil {
+			series.Refresh()
+		}
+	}()
+}
+
+// startRootsChecker checks the availability of the library roots every rootsCheckInterval
+func startRootsChecker() {
+	go func() {
+		for range time.Tick(rootsCheckInterval) {
+			checkLibraryRoots()
+		}
+	}()
+}
+
+// rootNames returns the names of all the roots of the library, the main one first
+func rootNames() []string {
+	var names []string
+	for _, root := range currentConfig().Config.LibraryRoots() {
+		names = append(names, root.Name)
+	}
+	return names
+}
+
+// formatLibraryRoots returns the other roots of the library as "name=path" lines
+func formatLibraryRoots(roots []settings.LibraryRoot) string {
+	var lines []string
+	for _, root := range roots {
+		lines = append(lines, fmt.Sprintf("%s=%s", root.Name, root.Path))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseLibraryRoots reads "name=path" lines, the path may contain '='
+func parseLibraryRoots(value string) ([]settings.LibraryRoot, error) {
+	var roots []settings.LibraryRoot
+	for _, line := range strings.Split(value, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "=", 2)
+		if len(fields) != 2 || strings.TrimSpace(fields[0]) == "" || strings.TrimSpace(fields[1]) == "" {
+			return nil, errors.New(fmt.Sprintf("invalid library root %s, expected name=path", strings.TrimSpace(line)))
+		}
+		if strings.TrimSpace(fields[0]) == settings.MainRoot {
+			return nil, errors.New(fmt.Sprintf("the name %s is used by the library folder", settings.MainRoot))
+		}
+		roots = append(roots, settings.LibraryRoot{Name: strings.TrimSpace(fields[0]), Path: strings.TrimSpace(fields[1])})
+	}
+	return roots, nil
+}
+
+// setLibraryRoots saves the other roots of the library, watches them, and displays the library again
+func setLibraryRoots(roots []settings.LibraryRoot) error {
+	if formatLibraryRoots(roots) == formatLibraryRoots(currentConfig().Config.Roots) {
+		return nil
+	}
+	var err error
+	updateConfig(func(cfg settings.Settings) settings.Settings {
+		cfg, err = settings.SetLibraryRoots(cfg, roots)
+		return cfg
+	})
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, name := range rootNames() {
+		found = found || name == libraryRoot
+	}
+	if !found {
+		// the root displayed was removed
+		libraryRoot = ""
+	}
+	if watcher != nil {
+		watcher.watchLibrary(currentConfig().Config)
+	}
+	reloadLibrary(4)
+	return nil
+}
+
+/*
+moveSeries moves the folder of a manga to another library root. the widgets sharing the manga follow
+it to its new folder.
+*/
+func moveSeries(manga *settings.Manga, root string, progress func(done, total int)) error {
+	resume := suspendWatcher()
+	defer resume()
+	closeReader(manga.Title)
+	// a chapter being downloaded is written before the move, or in the new folder, see downloadChapter
+	unlock := lockSeries(manga.Title)
+	defer unlock()
+	// the folder is moved without holding the settings, then only the new location of the manga is saved
+	_, moved, err := settings.MoveSeries(currentConfig(), *manga, root, progress)
+	if moved.Root != manga.Root || moved.Path != manga.Path {
+		updateManga(manga.Title, func(m *settings.Manga) {
+			m.Root, m.Path = moved.Root, moved.Path
+		})
+	}
+	if library != nil {
+		for _, tb := range library.Items {
+			if tb.Title.Title == moved.Title {
+				tb.Title.Root, tb.Title.Path = moved.Root, moved.Path
+			}
+		}
+	}
+	manga.Root, manga.Path = moved.Root, moved.Path
+	return err
+}
+
+// showMoveSeriesDialog asks the user to which root a manga must be moved, then moves it while displaying the progress
+func showMoveSeriesDialog(manga *settings.Manga) {
+	var targets []string
+	for _, name := range rootNames() {
+		if name != manga.RootName() {
+			targets = append(targets, name)
+		}
+	}
+	roots := widget.NewSelect(targets, nil)
+	if len(targets) > 0 {
+		roots.SetSelected(targets[0])
+	}
+	items := []*widget.FormItem{
+		widget.NewFormItem("Current root", widget.NewLabel(manga.RootName())),
+		widget.NewFormItem("New root", roots),
+	}
+	dialog.ShowForm(fmt.Sprintf("Move %s", manga.Name), "Move", "Cancel", items, func(ok bool) {
+		if !ok || roots.Selected == "" {
+			return
+		}
+		root := roots.Selected
+		progress := dialog.NewProgress("Move to root", fmt.Sprintf("Moving %s to the library root %s...", manga.Name, root), mainWindow)
+		progress.Show()
+		go func() {
+			err := moveSeries(manga, root, func(done, total int) {
+				progress.SetValue(float64(done) / float64(total))
+			})
+			progress.Hide()
+			if library != nil {
+				library.Refresh()
+			}
+			if series != nil {
+				series.Refresh()
+			}
+			if chapters != nil {
+				chapters.Refresh()
+			}
+			if err != nil {
+				dialog.ShowError(err, mainWindow)
+				return
+			}
+			dialog.ShowInformation("Move to root", fmt.Sprintf("%s is now in the library root %s.", manga.Name, manga.RootName()), mainWindow)
+		}()
+	}, mainWindow)
+}
@@ -9,13 +9,16 @@ import (
 	"github.com/francoiscolombo/gomangareader/settings"
 	"image/color"
 	"sort"
+	"strings"
 )
 
 type Search struct {
 	widget.BaseWidget
-	Provider         string
-	Search           string
-	Results          []settings.Manga
+	Provider string
+	Search   string
+	Results  []settings.Manga
+	// Filter is the genre that the results must have, empty for all
+	Filter           string
 	SearchInProgress bool
 }
 
@@ -44,13 +47,18 @@ func (s *Search) CreateRenderer() fyne.WidgetRenderer {
 
 	searchEntry := widget.NewEntry()
 
+	filterEntry := widget.NewSelectEntry(settings.Genres(currentConfig().History.Titles))
+	filterEntry.SetPlaceHolder("any genre")
+
 	searchForm := &widget.Form{
 		Items: []*widget.FormItem{
 			{Text: "What title do you search?", Widget: searchEntry},
+			{Text: "Only with", Widget: filterEntry},
 		},
 		OnSubmit: func() {
 			s.SearchInProgress = true
 			s.Search = searchEntry.Text
+			s.Filter = strings.TrimSpace(filterEntry.Text)
 			s.Refresh()
 		},
 		SubmitText: "Let's search for these titles!",
@@ -100,11 +108,11 @@ func (s *SearchRenderer) Destroy() {
 }
 
 func (s *SearchRenderer) MinSize() fyne.Size {
-	height := config.Config.ThumbTextHeight + theme.Padding()*2
+	height := currentConfig().Config.ThumbTextHeight + theme.Padding()*2
 	for _, i := range s.items {
 		height = height + i.Size().Height
 	}
-	return fyne.NewSize(config.Config.ThumbnailWidth*config.Config.NbColumns, height)
+	return fyne.NewSize(currentConfig().Config.ThumbnailWidth*currentConfig().Config.NbColumns, height)
 }
 
 func (s *SearchRenderer) Layout(_ fyne.Size) {
@@ -112,18 +120,18 @@ func (s *SearchRenderer) Layout(_ fyne.Size) {
 	dx := p
 	dy := p
 
-	s.form.Resize(fyne.NewSize(config.Config.ThumbnailWidth*config.Config.NbColumns, config.Config.ThumbTextHeight*3))
+	s.form.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*currentConfig().Config.NbColumns, currentConfig().Config.ThumbTextHeight*4))
 	s.form.Move(fyne.NewPos(dx, dy))
-	dy = dy + config.Config.ThumbTextHeight*4 + p
+	dy = dy + currentConfig().Config.ThumbTextHeight*5 + p
 
-	s.label.Resize(fyne.NewSize(config.Config.ThumbnailWidth*config.Config.NbColumns-p*2, config.Config.ThumbTextHeight))
+	s.label.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*currentConfig().Config.NbColumns-p*2, currentConfig().Config.ThumbTextHeight))
 	s.label.Move(fyne.NewPos(dx, dy))
-	dy = dy + p + config.Config.ThumbTextHeight
+	dy = dy + p + currentConfig().Config.ThumbTextHeight
 
 	for _, i := range s.items {
 		i.Resize(i.MinSize())
 		i.Move(fyne.NewPos(dx, dy))
-		dy = dy + p + config.Config.ThumbMiniHeight
+		dy = dy + p + currentConfig().Config.ThumbMiniHeight
 	}
 }
 
@@ -147,13 +155,16 @@ func (s *SearchRenderer) Refresh() {
 		s.label.Refresh()
 		p := settings.MangaReader{}
 		if s.search.Search != "" {
-			s.search.Results = p.SearchManga(config.Config.LibraryPath, s.search.Search)
+			s.search.Results = p.SearchManga(currentConfig().Config.LibraryPath, s.search.Search)
 			sort.Slice(s.search.Results, func(i, j int) bool {
 				return s.search.Results[i].Title < s.search.Results[j].Title
 			})
 		}
 		s.items = []*SearchItem{}
 		for _, r := range s.search.Results {
+			if !r.HasGenre(s.search.Filter) {
+				continue
+			}
 			item := NewSearchItem(r)
 			s.items = append(s.items, item)
 		}
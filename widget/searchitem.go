@@ -9,7 +9,7 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/francoiscolombo/gomangareader/settings"
 	"image/color"
-	"sort"
+	"log"
 )
 
 type SearchItem struct {
@@ -44,48 +44,23 @@ func (si *SearchItem) Tapped(*fyne.PointEvent) {
 					si.isSelected = true
 					si.Refresh()
 					provider := settings.MangaReader{}
-					newManga := provider.FindDetails(config.Config.LibraryPath, si.MangaFound.Title, 0)
+					newManga := provider.FindDetails(currentConfig().Config.LibraryPath, si.MangaFound.Title, 0)
+					newManga.Path = settings.UniqueSeriesPath(currentConfig(), newManga)
 					provider.BuildChaptersList(&newManga)
-					// download cover picture (if needed)
-					err1 := downloadCover(newManga)
-					if err1 == nil {
-						// and generate thumbnails (if needed)
-						err2 := extractFirstPages(config.Config.LibraryPath, newManga)
-						if err2 == nil {
-							// update library
-							ws := NewTitleButton(newManga)
-							library.Add(ws)
-							library.Refresh()
-							// and update history
-							config.History.Titles = append(config.History.Titles, newManga)
-							sort.Slice(config.History.Titles, func(i, j int) bool {
-								return config.History.Titles[i].Title < config.History.Titles[j].Title
-							})
-							// okay we have updated the metadata, now we can save the config
-							newSettings := settings.Settings{
-								Config: settings.Config{
-									LibraryPath:          config.Config.LibraryPath,
-									AutoUpdate:           config.Config.AutoUpdate,
-									NbColumns:            config.Config.NbColumns,
-									NbRows:               config.Config.NbRows,
-									PageWidth:            config.Config.PageWidth,
-									PageHeight:           config.Config.PageHeight,
-									ThumbMiniWidth:       config.Config.ThumbMiniWidth,
-									ThumbMiniHeight:      config.Config.ThumbMiniHeight,
-									LeftRightButtonWidth: config.Config.LeftRightButtonWidth,
-									ChapterLabelWidth:    config.Config.ChapterLabelWidth,
-									ThumbnailWidth:       config.Config.ThumbnailWidth,
-									ThumbnailHeight:      config.Config.ThumbnailHeight,
-									ThumbTextHeight:      config.Config.ThumbTextHeight,
-									NbWorkers:            config.Config.NbWorkers,
-								},
-								History: settings.History{
-									Titles: config.History.Titles,
-								},
-							}
-							settings.WriteSettings(newSettings)
-						}
+					// update history, unless the manga was added meanwhile
+					if !addManga(newManga) {
+						log.Printf("%s is already in the library", newManga.Title)
+						return
 					}
+					// download cover picture (if needed), or use a fallback
+					err := ensureCover(newManga)
+					if err != nil {
+						log.Printf("Error when trying to get a cover for %s: %s", newManga.Title, err)
+					}
+					// update library
+					ws := NewTitleButton(newManga)
+					library.Add(ws)
+					library.Refresh()
 				}
 			},
 			mainWindow,
@@ -172,30 +147,30 @@ func (s *SearchItemRender) Destroy() {
 }
 
 func (s *SearchItemRender) MinSize() fyne.Size {
-	return fyne.NewSize(config.Config.ThumbnailWidth*config.Config.NbColumns, config.Config.ThumbMiniHeight+theme.Padding()*2)
+	return fyne.NewSize(currentConfig().Config.ThumbnailWidth*currentConfig().Config.NbColumns, currentConfig().Config.ThumbMiniHeight+theme.Padding()*2)
 }
 
 func (s *SearchItemRender) Layout(_ fyne.Size) {
 	p := theme.Padding()
 
-	s.lineUp.Resize(fyne.NewSize(config.Config.ThumbnailWidth*config.Config.NbColumns, 1))
+	s.lineUp.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*currentConfig().Config.NbColumns, 1))
 	s.lineUp.Move(fyne.NewPos(0, 1))
 
-	s.lineDown.Resize(fyne.NewSize(config.Config.ThumbnailWidth*config.Config.NbColumns, 1))
-	s.lineDown.Move(fyne.NewPos(0, config.Config.ThumbMiniHeight+p*2))
+	s.lineDown.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*currentConfig().Config.NbColumns, 1))
+	s.lineDown.Move(fyne.NewPos(0, currentConfig().Config.ThumbMiniHeight+p*2))
 
 	dx := p
 	dy := p
 
-	s.thumbnail.Resize(fyne.NewSize(config.Config.ThumbMiniWidth, config.Config.ThumbMiniHeight))
+	s.thumbnail.Resize(fyne.NewSize(currentConfig().Config.ThumbMiniWidth, currentConfig().Config.ThumbMiniHeight))
 	s.thumbnail.Move(fyne.NewPos(dx, dy))
-	dx = dx + p + config.Config.ThumbMiniWidth
+	dx = dx + p + currentConfig().Config.ThumbMiniWidth
 
-	s.title.Resize(fyne.NewSize(config.Config.ThumbnailWidth*config.Config.NbColumns-p-dx, config.Config.ThumbTextHeight))
+	s.title.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*currentConfig().Config.NbColumns-p-dx, currentConfig().Config.ThumbTextHeight))
 	s.title.Move(fyne.NewPos(dx, dy))
-	dy = dy + config.Config.ThumbTextHeight + p
+	dy = dy + currentConfig().Config.ThumbTextHeight + p
 
-	s.description.Resize(fyne.NewSize(config.Config.ThumbnailWidth*config.Config.NbColumns-p-dx, config.Config.ThumbMiniHeight))
+	s.description.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*currentConfig().Config.NbColumns-p-dx, currentConfig().Config.ThumbMiniHeight))
 	s.description.Move(fyne.NewPos(dx, dy))
 
 }
@@ -221,7 +196,7 @@ func (s *SearchItemRender) Refresh() {
 }
 
 func checkMangaAlreadyInLibrary(manga settings.Manga) bool {
-	for _, m := range config.History.Titles {
+	for _, m := range currentConfig().History.Titles {
 		if m.Title == manga.Title {
 			return true
 		}
@@ -33,27 +33,55 @@ func (s *Series) MinSize() fyne.Size {
 func (s *Series) CreateRenderer() fyne.WidgetRenderer {
 	s.ExtendBaseWidget(s)
 
-	var cover *canvas.Image
-	if s.SelectedManga.CoverPath != "" {
-		cover = canvas.NewImageFromFile(s.SelectedManga.CoverPath)
-		cover.FillMode = canvas.ImageFillContain
+	cover := canvas.NewImageFromFile(coverFile(*s.SelectedManga))
+	cover.FillMode = canvas.ImageFillContain
+
+	changeCover := widget.NewButtonWithIcon("Change cover...", theme.MediaPhotoIcon(), func() {
+		showCoverDialog(s.SelectedManga)
+	})
+	editDetails := widget.NewButtonWithIcon("Edit details...", theme.DocumentCreateIcon(), func() {
+		showDetailsDialog(s.SelectedManga)
+	})
+	resetDetailsButton := widget.NewButtonWithIcon("Reset to provider", theme.ContentUndoIcon(), func() {
+		resetDetails(s.SelectedManga)
+	})
+	if len(s.SelectedManga.Overrides) == 0 {
+		resetDetailsButton.Disable()
 	}
+	moveRoot := widget.NewButtonWithIcon("Move to root...", theme.FolderIcon(), func() {
+		showMoveSeriesDialog(s.SelectedManga)
+	})
+	if len(currentConfig().Config.Roots) == 0 {
+		moveRoot.Disable()
+	}
+	remove := widget.NewButtonWithIcon("Remove...", theme.DeleteIcon(), func() {
+		showRemoveSeriesDialog(s.SelectedManga)
+	})
 
-	lName := canvas.NewText("Name:", theme.ForegroundColor())
+	lName := canvas.NewText(detailLabel(*s.SelectedManga, "name")+":", theme.ForegroundColor())
 	lName.TextSize = 12
 
-	lAlternateName := canvas.NewText("Alternate Name:", theme.ForegroundColor())
+	lAlternateName := canvas.NewText(detailLabel(*s.SelectedManga, "alternate_name")+":", theme.ForegroundColor())
 	lAlternateName.TextSize = 12
 
-	lStatus := canvas.NewText("Status:", theme.ForegroundColor())
+	lStatus := canvas.NewText(detailLabel(*s.SelectedManga, "status")+":", theme.ForegroundColor())
 	lStatus.TextSize = 12
 
 	lNbOfChapters := canvas.NewText("Nb of chapters:", theme.ForegroundColor())
 	lNbOfChapters.TextSize = 12
 
-	lAuthor := canvas.NewText("Author:", theme.ForegroundColor())
+	lAuthor := canvas.NewText(detailLabel(*s.SelectedManga, "author")+":", theme.ForegroundColor())
 	lAuthor.TextSize = 12
 
+	lGenres := canvas.NewText(detailLabel(*s.SelectedManga, "genres")+":", theme.ForegroundColor())
+	lGenres.TextSize = 12
+
+	lTags := canvas.NewText(detailLabel(*s.SelectedManga, "tags")+":", theme.ForegroundColor())
+	lTags.TextSize = 12
+
+	lContentRating := canvas.NewText(detailLabel(*s.SelectedManga, "content_rating")+":", theme.ForegroundColor())
+	lContentRating.TextSize = 12
+
 	lAvailability := canvas.NewText("Availability:", theme.ForegroundColor())
 	lAvailability.TextSize = 12
 
@@ -72,13 +100,41 @@ func (s *Series) CreateRenderer() fyne.WidgetRenderer {
 	txtAuthor := canvas.NewText(s.SelectedManga.Author, theme.ForegroundColor())
 	txtAuthor.TextSize = 12
 
-	updated := checkNewChapters(s.SelectedManga)
-	txtAvailability := canvas.NewText("complete", color.NRGBA{R: 0x80, G: 0x80, B: 0xff, A: 0xff})
-	if updated {
-		txtAvailability = canvas.NewText("new chapters available", color.NRGBA{R: 0xff, G: 0x80, B: 0x80, A: 0xff})
-	}
+	txtGenres := canvas.NewText(s.SelectedManga.Detail("genres"), theme.ForegroundColor())
+	txtGenres.TextSize = 12
+
+	txtTags := canvas.NewText(s.SelectedManga.Detail("tags"), theme.ForegroundColor())
+	txtTags.TextSize = 12
+
+	txtContentRating := canvas.NewText(s.SelectedManga.ContentRating, theme.ForegroundColor())
+	txtContentRating.TextSize = 12
+
+	txtAvailability := canvas.NewText("", theme.ForegroundColor())
+	setAvailability(txtAvailability, *s.SelectedManga)
 	txtAvailability.TextSize = 12
 
+	updateCheck := widget.NewCheck("Check for new chapters in background", func(checked bool) {
+		if s.SelectedManga.SkipUpdateCheck == !checked {
+			return
+		}
+		s.SelectedManga.SkipUpdateCheck = !checked
+		updateManga(s.SelectedManga.Title, func(m *settings.Manga) {
+			m.SkipUpdateCheck = !checked
+		})
+	})
+	updateCheck.Checked = !s.SelectedManga.SkipUpdateCheck
+
+	autoDownload := widget.NewCheck("Download new chapters automatically", func(checked bool) {
+		if s.SelectedManga.AutoDownloadEnabled(currentConfig().Config) == checked {
+			return
+		}
+		s.SelectedManga.AutoDownload = &checked
+		updateManga(s.SelectedManga.Title, func(m *settings.Manga) {
+			m.AutoDownload = &checked
+		})
+	})
+	autoDownload.Checked = s.SelectedManga.AutoDownloadEnabled(currentConfig().Config)
+
 	txtDescription := widget.NewLabel(s.SelectedManga.Description)
 	txtDescription.Wrapping = fyne.TextWrapWord
 	txtDescription.TextStyle = fyne.TextStyle{
@@ -90,46 +146,74 @@ func (s *Series) CreateRenderer() fyne.WidgetRenderer {
 	bg := canvas.NewRectangle(theme.ButtonColor())
 
 	sr := &SeriesRenderer{
-		cover:          cover,
-		lname:          lName,
-		name:           txtName,
-		lalternateName: lAlternateName,
-		alternateName:  txtAlternateName,
-		lstatus:        lStatus,
-		status:         txtStatus,
-		lnbOfChapters:  lNbOfChapters,
-		nbOfChapters:   txtNbOfChapters,
-		lauthor:        lAuthor,
-		author:         txtAuthor,
-		lavailability:  lAvailability,
-		availability:   txtAvailability,
-		description:    container.NewScroll(txtDescription),
-		bg:             bg,
-		layout:         nil,
-		series:         s,
+		cover:           cover,
+		lname:           lName,
+		name:            txtName,
+		lalternateName:  lAlternateName,
+		alternateName:   txtAlternateName,
+		lstatus:         lStatus,
+		status:          txtStatus,
+		lnbOfChapters:   lNbOfChapters,
+		nbOfChapters:    txtNbOfChapters,
+		lauthor:         lAuthor,
+		author:          txtAuthor,
+		lgenres:         lGenres,
+		genres:          txtGenres,
+		ltags:           lTags,
+		tags:            txtTags,
+		lcontentRating:  lContentRating,
+		contentRating:   txtContentRating,
+		lavailability:   lAvailability,
+		availability:    txtAvailability,
+		updateCheck:     updateCheck,
+		autoDownload:    autoDownload,
+		changeCover:     changeCover,
+		editDetails:     editDetails,
+		resetDetails:    resetDetailsButton,
+		moveRoot:        moveRoot,
+		remove:          remove,
+		descriptionText: txtDescription,
+		description:     container.NewScroll(txtDescription),
+		bg:              bg,
+		layout:          nil,
+		series:          s,
 	}
 
 	return sr
 }
 
 type SeriesRenderer struct {
-	cover          *canvas.Image
-	lname          *canvas.Text
-	lalternateName *canvas.Text
-	lstatus        *canvas.Text
-	lnbOfChapters  *canvas.Text
-	lauthor        *canvas.Text
-	lavailability  *canvas.Text
-	name           *canvas.Text
-	alternateName  *canvas.Text
-	status         *canvas.Text
-	nbOfChapters   *canvas.Text
-	author         *canvas.Text
-	availability   *canvas.Text
-	description    *container.Scroll
-	bg             *canvas.Rectangle
-	layout         fyne.Layout
-	series         *Series
+	cover           *canvas.Image
+	lname           *canvas.Text
+	lalternateName  *canvas.Text
+	lstatus         *canvas.Text
+	lnbOfChapters   *canvas.Text
+	lauthor         *canvas.Text
+	lgenres         *canvas.Text
+	ltags           *canvas.Text
+	lcontentRating  *canvas.Text
+	lavailability   *canvas.Text
+	name            *canvas.Text
+	alternateName   *canvas.Text
+	status          *canvas.Text
+	nbOfChapters    *canvas.Text
+	author          *canvas.Text
+	genres          *canvas.Text
+	tags            *canvas.Text
+	contentRating   *canvas.Text
+	availability    *canvas.Text
+	updateCheck     *widget.Check
+	autoDownload    *widget.Check
+	changeCover     *widget.Button
+	editDetails     *widget.Button
+	resetDetails    *widget.Button
+	moveRoot        *widget.Button
+	remove          *widget.Button
+	descriptionText *widget.Label
+	description     *container.Scroll
+	bg              *canvas.Rectangle
+	layout          fyne.Layout
+	series          *Series
 }
 
 func (s *SeriesRenderer) BackgroundColor() color.Color {
@@ -143,13 +227,27 @@ func (s *SeriesRenderer) Destroy() {
 	s.status = nil
 	s.nbOfChapters = nil
 	s.author = nil
+	s.genres = nil
+	s.tags = nil
+	s.contentRating = nil
 	s.availability = nil
+	s.updateCheck = nil
+	s.autoDownload = nil
+	s.changeCover = nil
+	s.editDetails = nil
+	s.resetDetails = nil
+	s.moveRoot = nil
+	s.remove = nil
+	s.descriptionText = nil
 	s.description = nil
 	s.lname = nil
 	s.lalternateName = nil
 	s.lstatus = nil
 	s.lnbOfChapters = nil
 	s.lauthor = nil
+	s.lgenres = nil
+	s.ltags = nil
+	s.lcontentRating = nil
 	s.lavailability = nil
 	s.bg = nil
 	s.layout = nil
@@ -159,12 +257,12 @@ func (s *SeriesRenderer) Destroy() {
 func (s *SeriesRenderer) Layout(_ fyne.Size) {
 	var txtHeight float32
 	p := theme.Padding()
-	ldx := (config.Config.ThumbnailWidth + p) * 2
-	dx := (config.Config.ThumbnailWidth + p) * 3
+	ldx := (currentConfig().Config.ThumbnailWidth + p) * 2
+	dx := (currentConfig().Config.ThumbnailWidth + p) * 3
 	dy := p
 	txtHeight = 20.0
 
-	s.cover.Resize(fyne.NewSize(config.Config.ThumbnailWidth*2, config.Config.ThumbnailHeight*2))
+	s.cover.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*2, currentConfig().Config.ThumbnailHeight*2))
 	s.cover.Move(fyne.NewPos(p, p))
 
 	s.lname.Move(fyne.NewPos(ldx, dy))
@@ -187,17 +285,47 @@ func (s *SeriesRenderer) Layout(_ fyne.Size) {
 	s.author.Move(fyne.NewPos(dx, dy))
 	dy = dy + txtHeight + p
 
+	s.lgenres.Move(fyne.NewPos(ldx, dy))
+	s.genres.Move(fyne.NewPos(dx, dy))
+	dy = dy + txtHeight + p
+
+	s.ltags.Move(fyne.NewPos(ldx, dy))
+	s.tags.Move(fyne.NewPos(dx, dy))
+	dy = dy + txtHeight + p
+
+	s.lcontentRating.Move(fyne.NewPos(ldx, dy))
+	s.contentRating.Move(fyne.NewPos(dx, dy))
+	dy = dy + txtHeight + p
+
 	s.lavailability.Move(fyne.NewPos(ldx, dy))
 	s.availability.Move(fyne.NewPos(dx, dy))
 	dy = dy + txtHeight + p
 
-	s.description.Resize(fyne.NewSize(config.Config.ThumbnailWidth*4, config.Config.ThumbTextHeight*12))
+	s.updateCheck.Resize(s.updateCheck.MinSize())
+	s.updateCheck.Move(fyne.NewPos(ldx, dy))
+	s.autoDownload.Resize(s.autoDownload.MinSize())
+	s.autoDownload.Move(fyne.NewPos(ldx+s.updateCheck.MinSize().Width+p, dy))
+	dy = dy + s.updateCheck.MinSize().Height + p
+
+	s.changeCover.Resize(s.changeCover.MinSize())
+	s.changeCover.Move(fyne.NewPos(ldx, dy))
+	s.editDetails.Resize(s.editDetails.MinSize())
+	s.editDetails.Move(fyne.NewPos(ldx+s.changeCover.MinSize().Width+p, dy))
+	s.resetDetails.Resize(s.resetDetails.MinSize())
+	s.resetDetails.Move(fyne.NewPos(ldx+s.changeCover.MinSize().Width+s.editDetails.MinSize().Width+p*2, dy))
+	s.moveRoot.Resize(s.moveRoot.MinSize())
+	s.moveRoot.Move(fyne.NewPos(ldx+s.changeCover.MinSize().Width+s.editDetails.MinSize().Width+s.resetDetails.MinSize().Width+p*3, dy))
+	s.remove.Resize(s.remove.MinSize())
+	s.remove.Move(fyne.NewPos(ldx+s.changeCover.MinSize().Width+s.editDetails.MinSize().Width+s.resetDetails.MinSize().Width+s.moveRoot.MinSize().Width+p*4, dy))
+	dy = dy + s.changeCover.MinSize().Height + p
+
+	s.description.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth*4, currentConfig().Config.ThumbTextHeight*12))
 	s.description.Move(fyne.NewPos(ldx, dy))
 
 }
 
 func (s *SeriesRenderer) MinSize() fyne.Size {
-	return fyne.NewSize(config.Config.ThumbnailWidth*6+theme.Padding()*2, config.Config.ThumbnailHeight*2+theme.Padding()*2)
+	return fyne.NewSize(currentConfig().Config.ThumbnailWidth*6+theme.Padding()*2, currentConfig().Config.ThumbnailHeight*2+theme.Padding()*2)
 }
 
 func (s *SeriesRenderer) Objects() []fyne.CanvasObject {
@@ -213,26 +341,90 @@ func (s *SeriesRenderer) Objects() []fyne.CanvasObject {
 	objects = append(objects, s.nbOfChapters)
 	objects = append(objects, s.lauthor)
 	objects = append(objects, s.author)
+	objects = append(objects, s.lgenres)
+	objects = append(objects, s.genres)
+	objects = append(objects, s.ltags)
+	objects = append(objects, s.tags)
+	objects = append(objects, s.lcontentRating)
+	objects = append(objects, s.contentRating)
 	objects = append(objects, s.lavailability)
 	objects = append(objects, s.availability)
+	objects = append(objects, s.updateCheck)
+	objects = append(objects, s.autoDownload)
+	objects = append(objects, s.changeCover)
+	objects = append(objects, s.editDetails)
+	objects = append(objects, s.resetDetails)
+	objects = append(objects, s.moveRoot)
+	objects = append(objects, s.remove)
 	objects = append(objects, s.description)
 	return objects
 }
 
 func (s *SeriesRenderer) Refresh() {
 	s.bg.Refresh()
+	s.cover.File = coverFile(*s.series.SelectedManga)
 	s.cover.Refresh()
+	manga := s.series.SelectedManga
+	s.lname.Text = detailLabel(*manga, "name") + ":"
 	s.lname.Refresh()
+	s.name.Text = manga.Name
 	s.name.Refresh()
+	s.lalternateName.Text = detailLabel(*manga, "alternate_name") + ":"
 	s.lalternateName.Refresh()
+	s.alternateName.Text = manga.AlternateName
 	s.alternateName.Refresh()
+	s.lstatus.Text = detailLabel(*manga, "status") + ":"
 	s.lstatus.Refresh()
+	s.status.Text = manga.Status
 	s.status.Refresh()
 	s.lnbOfChapters.Refresh()
 	s.nbOfChapters.Refresh()
+	s.lauthor.Text = detailLabel(*manga, "author") + ":"
 	s.lauthor.Refresh()
+	s.author.Text = manga.Author
 	s.author.Refresh()
+	s.lgenres.Text = detailLabel(*manga, "genres") + ":"
+	s.lgenres.Refresh()
+	s.genres.Text = manga.Detail("genres")
+	s.genres.Refresh()
+	s.ltags.Text = detailLabel(*manga, "tags") + ":"
+	s.ltags.Refresh()
+	s.tags.Text = manga.Detail("tags")
+	s.tags.Refresh()
+	s.lcontentRating.Text = detailLabel(*manga, "content_rating") + ":"
+	s.lcontentRating.Refresh()
+	s.contentRating.Text = manga.ContentRating
+	s.contentRating.Refresh()
 	s.lavailability.Refresh()
+	setAvailability(s.availability, *manga)
 	s.availability.Refresh()
+	s.updateCheck.Refresh()
+	s.autoDownload.Refresh()
+	s.changeCover.Refresh()
+	s.editDetails.Refresh()
+	if len(manga.Overrides) == 0 {
+		s.resetDetails.Disable()
+	} else {
+		s.resetDetails.Enable()
+	}
+	if len(currentConfig().Config.Roots) == 0 {
+		s.moveRoot.Disable()
+	} else {
+		s.moveRoot.Enable()
+	}
+	s.descriptionText.SetText(manga.Description)
 	s.description.Refresh()
 }
+
+func setAvailability(text *canvas.Text, manga settings.Manga) {
+	if !rootAvailable(manga.Root) {
+		text.Text = fmt.Sprintf("unavailable, the library root %s is offline", manga.RootName())
+		text.Color = offlineColor
+	} else if manga.NewChaptersAvailable() {
+		text.Text = "new chapters available"
+		text.Color = color.NRGBA{R: 0xff, G: 0x80, B: 0x80, A: 0xff}
+	} else {
+		text.Text = "complete"
+		text.Color = color.NRGBA{R: 0x80, G: 0x80, B: 0xff, A: 0xff}
+	}
+}
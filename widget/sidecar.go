@@ -0,0 +1,38 @@
+package widget
+
+import (
+	"github.com/francoiscolombo/gomangareader/settings"
+	"log"
+)
+
+// rebuildHistory puts the mangas described by the series.json files of the library in the history
+func rebuildHistory() (int, error) {
+	// the sidecars are read without holding the settings, then merged with the current history
+	titles, err := settings.ReadSidecars(currentConfig().Config)
+	if len(titles) == 0 {
+		return 0, err
+	}
+	updateConfig(func(cfg settings.Settings) settings.Settings {
+		cfg = settings.MergeSidecars(cfg, titles)
+		settings.WriteSettings(cfg)
+		return cfg
+	})
+	return len(titles), err
+}
+
+/*
+RebuildHistory reads the series.json files of the library, and puts the mangas they describe in the
+history, so the library can be used again after the settings file was lost. it returns the number of
+mangas found.
+*/
+func RebuildHistory() (int, error) {
+	if !configLoaded() {
+		if settings.IsSettingsExisting() == false {
+			settings.WriteDefaultSettings()
+		}
+		setConfig(settings.ReadSettings())
+	}
+	nbTitles, err := rebuildHistory()
+	log.Printf("History rebuilt from the library, %d titles found", nbTitles)
+	return nbTitles, err
+}
@@ -0,0 +1,99 @@
+package widget
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// throttleChunkSize is the biggest read allowed at once on a throttled stream, to keep the rate smooth
+const throttleChunkSize = 16 * 1024
+
+var limitersLock sync.Mutex
+var limiters = map[string]*bandwidthLimiter{}
+
+/*
+bandwidthLimiter is a token bucket shared by all the streams of the same scope (global or provider).
+the bucket holds at most one second of data, so bursts stay short.
+*/
+type bandwidthLimiter struct {
+	mu        sync.Mutex
+	rate      int
+	allowance float64
+	last      time.Time
+}
+
+// wait blocks until n bytes can be consumed without going over the rate
+func (l *bandwidthLimiter) wait(n int) {
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.allowance += now.Sub(l.last).Seconds() * float64(l.rate)
+	}
+	if l.allowance > float64(l.rate) {
+		l.allowance = float64(l.rate)
+	}
+	l.last = now
+	l.allowance -= float64(n)
+	var delay time.Duration
+	if l.allowance < 0 {
+		delay = time.Duration(-l.allowance / float64(l.rate) * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// limiterFor returns the limiter of a scope, updated with the current rate in KB/s
+func limiterFor(scope string, rate int) *bandwidthLimiter {
+	limitersLock.Lock()
+	defer limitersLock.Unlock()
+	l, ok := limiters[scope]
+	if !ok {
+		l = &bandwidthLimiter{}
+		limiters[scope] = l
+	}
+	l.mu.Lock()
+	l.rate = rate * 1024
+	l.mu.Unlock()
+	return l
+}
+
+type throttledReader struct {
+	reader   io.Reader
+	limiters []*bandwidthLimiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > throttleChunkSize {
+		p = p[:throttleChunkSize]
+	}
+	n, err := t.reader.Read(p)
+	for _, l := range t.limiters {
+		l.wait(n)
+	}
+	return n, err
+}
+
+/*
+throttle wraps a download stream so it respects the global bandwidth cap and the cap of the provider,
+when they are configured.
+*/
+func throttle(reader io.Reader, provider string) io.Reader {
+	var active []*bandwidthLimiter
+	if currentConfig().Config.BandwidthLimit > 0 {
+		active = append(active, limiterFor("", currentConfig().Config.BandwidthLimit))
+	}
+	if rate := currentConfig().Config.ProviderBandwidthLimits[provider]; rate > 0 {
+		active = append(active, limiterFor(provider, rate))
+	}
+	if len(active) == 0 {
+		return reader
+	}
+	return &throttledReader{reader: reader, limiters: active}
+}
@@ -0,0 +1,141 @@
+package widget
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/francoiscolombo/gomangareader/settings"
+	"golang.org/x/image/draw"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// thumbnailScale keeps the thumbnails sharp on high density screens
+	thumbnailScale   = 2
+	thumbnailQuality = 80
+	// thumbnailsMarker is created when the thumbnails are generated by resizing, the older ones are full pages
+	thumbnailsMarker = ".thumbnails-v2"
+)
+
+var thumbnailsSince time.Time
+var thumbnailsSinceOnce sync.Once
+
+/*
+makeThumbnail decodes an image and downscales it to fit in width x height, with a high quality filter.
+the thumbnail is encoded as a jpeg, so it stays small whatever the format of the page.
+*/
+func makeThumbnail(data []byte, width, height float32) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return nil, errors.New("image is empty")
+	}
+	maxWidth := int(width * thumbnailScale)
+	maxHeight := int(height * thumbnailScale)
+	ratio := float64(maxWidth) / float64(bounds.Dx())
+	if r := float64(maxHeight) / float64(bounds.Dy()); r < ratio {
+		ratio = r
+	}
+	if ratio > 1 {
+		// never enlarge a small image
+		ratio = 1
+	}
+	w, h := int(float64(bounds.Dx())*ratio), int(float64(bounds.Dy())*ratio)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	var buffer bytes.Buffer
+	err = jpeg.Encode(&buffer, dst, &jpeg.Options{Quality: thumbnailQuality})
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// writeThumbnail writes the thumbnail of an image to path
+func writeThumbnail(path string, data []byte, width, height float32) error {
+	thumbnail, err := makeThumbnail(data, width, height)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error while resizing %s: %s", path, err))
+	}
+	err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, thumbnail, 0644)
+}
+
+/*
+thumbnailStale tells if a thumbnail must be generated again: when it does not exist, when its source
+changed after it was generated, or when it was copied full size by the previous versions.
+*/
+func thumbnailStale(thumbnail, source string) bool {
+	thumbInfo, err := os.Stat(thumbnail)
+	if err != nil {
+		return true
+	}
+	if sourceInfo, err := os.Stat(source); err == nil && sourceInfo.ModTime().After(thumbInfo.ModTime()) {
+		return true
+	}
+	return thumbInfo.ModTime().Before(resizedThumbnailsSince())
+}
+
+// resizedThumbnailsSince returns the time since when the thumbnails are resized
+func resizedThumbnailsSince() time.Time {
+	thumbnailsSinceOnce.Do(func() {
+		marker := filepath.Join(currentConfig().Config.LibraryPath, ".metadata", thumbnailsMarker)
+		info, err := os.Stat(marker)
+		if err == nil {
+			thumbnailsSince = info.ModTime()
+			return
+		}
+		thumbnailsSince = time.Now()
+		if err = os.MkdirAll(filepath.Dir(marker), os.ModePerm); err == nil {
+			_ = os.WriteFile(marker, []byte(thumbnailsSince.Format(time.RFC3339)), 0644)
+		}
+	})
+	return thumbnailsSince
+}
+
+// coverThumbnailPath returns the path of the cover resized for the library grid
+func coverThumbnailPath(manga settings.Manga) string {
+	return strings.TrimSuffix(manga.CoverPath, filepath.Ext(manga.CoverPath)) + "-grid.jpg"
+}
+
+// libraryCover returns the image to display in the library grid, the resized cover when it exists
+func libraryCover(manga settings.Manga) string {
+	if _, err := os.Stat(coverThumbnailPath(manga)); err == nil {
+		return coverThumbnailPath(manga)
+	}
+	return coverFile(manga)
+}
+
+// makeCoverThumbnail resizes the cover of a manga for the library grid, if needed
+func makeCoverThumbnail(manga settings.Manga) error {
+	thumbnail := coverThumbnailPath(manga)
+	cover := coverFile(manga)
+	if !thumbnailStale(thumbnail, cover) {
+		return nil
+	}
+	data, err := os.ReadFile(cover)
+	if err != nil {
+		return err
+	}
+	return <-getDownloadPool().Submit("", func() error {
+		return writeThumbnail(thumbnail, data, currentConfig().Config.ThumbnailWidth, currentConfig().Config.ThumbnailHeight)
+	})
+}
@@ -28,21 +28,10 @@ func NewTitleButton(title settings.Manga) *TitleButton {
 func (t *TitleButton) CreateRenderer() fyne.WidgetRenderer {
 	t.ExtendBaseWidget(t)
 
-	var cover *canvas.Image
-	if t.Title.CoverPath != "" {
-		cover = canvas.NewImageFromFile(t.Title.CoverPath)
-		cover.FillMode = canvas.ImageFillContain
-	}
+	cover := canvas.NewImageFromFile(libraryCover(*t.Title))
+	cover.FillMode = canvas.ImageFillContain
 
-	title := t.Title.Name
-	if len(title) > 20 {
-		title = title[0:17] + "..."
-	}
-	colorTitle := color.NRGBA{R: 0x80, G: 0xff, A: 0xff}
-	if checkNewChapters(t.Title) {
-		colorTitle = color.NRGBA{R: 0xff, G: 0x80, A: 0xff}
-	}
-	text := canvas.NewText(title, colorTitle)
+	text := canvas.NewText(shortTitle(t.Title.Name), titleColor(*t.Title))
 	text.TextSize = 10
 
 	bg := canvas.NewRectangle(theme.ButtonColor())
@@ -96,7 +85,7 @@ func (t *TitleButtonRenderer) Destroy() {
 }
 
 func (t *TitleButtonRenderer) MinSize() fyne.Size {
-	return fyne.NewSize(config.Config.ThumbnailWidth, config.Config.ThumbnailHeight+config.Config.ThumbTextHeight)
+	return fyne.NewSize(currentConfig().Config.ThumbnailWidth, currentConfig().Config.ThumbnailHeight+currentConfig().Config.ThumbTextHeight)
 }
 
 func (t *TitleButtonRenderer) Objects() []fyne.CanvasObject {
@@ -107,9 +96,9 @@ func (t *TitleButtonRenderer) Objects() []fyne.CanvasObject {
 
 func (t *TitleButtonRenderer) Layout(_ fyne.Size) {
 	//log.Printf(">>> method Layout with size %d/%d called on %s title button", size.Width, size.Height, t.title)
-	t.bg.Resize(fyne.NewSize(config.Config.ThumbnailWidth+theme.Padding()/2, config.Config.ThumbnailHeight+config.Config.ThumbTextHeight+theme.Padding()/2))
-	t.cover.SetMinSize(fyne.NewSize(config.Config.ThumbnailWidth, config.Config.ThumbnailHeight))
-	t.title.SetMinSize(fyne.NewSize(config.Config.ThumbnailWidth, config.Config.ThumbTextHeight))
+	t.bg.Resize(fyne.NewSize(currentConfig().Config.ThumbnailWidth+theme.Padding()/2, currentConfig().Config.ThumbnailHeight+currentConfig().Config.ThumbTextHeight+theme.Padding()/2))
+	t.cover.SetMinSize(fyne.NewSize(currentConfig().Config.ThumbnailWidth, currentConfig().Config.ThumbnailHeight))
+	t.title.SetMinSize(fyne.NewSize(currentConfig().Config.ThumbnailWidth, currentConfig().Config.ThumbTextHeight))
 	objects := []fyne.CanvasObject{t.cover, t.title}
 	min := t.layout.MinSize(objects)
 	t.layout.Layout(objects, min)
@@ -124,6 +113,7 @@ func (t *TitleButtonRenderer) Refresh() {
 		t.bg.FillColor = color.Transparent
 	}
 	t.bg.Refresh()
+	t.cover.File = libraryCover(*t.titleButton.Title)
 	t.cover.FillMode = canvas.ImageFillContain
 	t.cover.Refresh()
 	t.cover.Show()
@@ -133,18 +123,35 @@ func (t *TitleButtonRenderer) Refresh() {
 		Monospace: false,
 	}
 	t.title.Alignment = fyne.TextAlignCenter
+	t.title.Text = shortTitle(t.titleButton.Title.Name)
+	t.title.Color = titleColor(*t.titleButton.Title)
 	t.title.Refresh()
 	t.title.Show()
 	t.Layout(t.titleButton.Size())
 	canvas.Refresh(t.titleButton)
 }
 
-func checkNewChapters(manga *settings.Manga) bool {
-	var provider settings.MangaProvider
-	provider = settings.MangaReader{}
-	checkLastChapter := provider.CheckLastChapter(*manga)
-	if manga.LastChapter < checkLastChapter {
-		return true
+// shortTitle returns the name of a manga, cut to fit under its cover
+func shortTitle(name string) string {
+	if len(name) > 20 {
+		return name[0:17] + "..."
+	}
+	return name
+}
+
+// offlineColor is the color of the titles whose library root is offline
+var offlineColor = color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+
+/*
+titleColor returns the color of a title in the library, depending on the availability of new chapters.
+the titles whose library root is offline are greyed out.
+*/
+func titleColor(manga settings.Manga) color.Color {
+	if !rootAvailable(manga.Root) {
+		return offlineColor
+	}
+	if manga.NewChaptersAvailable() {
+		return color.NRGBA{R: 0xff, G: 0x80, A: 0xff}
 	}
-	return false
+	return color.NRGBA{R: 0x80, G: 0xff, A: 0xff}
 }
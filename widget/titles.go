@@ -22,6 +22,21 @@ func getLastChapterIndex(manga settings.Manga) int {
 	return index
 }
 
+// findManga returns the manga with the given title from the download history
+func findManga(title string) (settings.Manga, bool) {
+	return findMangaIn(currentConfig(), title)
+}
+
+// findMangaIn returns the manga with the given title from the history of the given settings
+func findMangaIn(cfg settings.Settings, title string) (settings.Manga, bool) {
+	for _, m := range cfg.History.Titles {
+		if m.Title == title {
+			return m, true
+		}
+	}
+	return settings.Manga{}, false
+}
+
 type Titles struct {
 	widget.BaseWidget
 	Items []*TitleButton
@@ -76,7 +91,7 @@ func (t *Titles) CreateRenderer() fyne.WidgetRenderer {
 	bg := canvas.NewRectangle(theme.ButtonColor())
 	r := &TitlesRenderer{
 		bg:        bg,
-		layout:    layout.NewGridLayout(int(config.Config.NbColumns)),
+		layout:    layout.NewGridLayout(int(currentConfig().Config.NbColumns)),
 		container: t,
 	}
 	return r
@@ -107,6 +122,17 @@ func (t *Titles) RemoveIndex(index int) {
 	t.Items = append(t.Items[:index], t.Items[index+1:]...)
 }
 
+// visibleItems returns the items matching the filter and the root displayed in the library
+func (t *Titles) visibleItems() []*TitleButton {
+	var items []*TitleButton
+	for _, tb := range t.Items {
+		if tb.Title.MatchesFilter(libraryFilter) && (libraryRoot == "" || tb.Title.RootName() == libraryRoot) {
+			items = append(items, tb)
+		}
+	}
+	return items
+}
+
 type TitlesRenderer struct {
 	bg        *canvas.Rectangle
 	layout    fyne.Layout
@@ -124,13 +150,13 @@ func (t *TitlesRenderer) Destroy() {
 
 func (t *TitlesRenderer) MinSize() fyne.Size {
 	var nbRows float32
-	nbRows = float32((len(t.container.Items) / 6) + 1)
-	return fyne.NewSize(config.Config.ThumbnailWidth*config.Config.NbColumns, (config.Config.ThumbnailHeight+config.Config.ThumbTextHeight)*nbRows+40)
+	nbRows = float32((len(t.container.visibleItems()) / 6) + 1)
+	return fyne.NewSize(currentConfig().Config.ThumbnailWidth*currentConfig().Config.NbColumns, (currentConfig().Config.ThumbnailHeight+currentConfig().Config.ThumbTextHeight)*nbRows+40)
 }
 
 func (t *TitlesRenderer) Objects() []fyne.CanvasObject {
 	var objects []fyne.CanvasObject
-	for _, tb := range t.container.Items {
+	for _, tb := range t.container.visibleItems() {
 		objects = append(objects, tb)
 	}
 	return objects
@@ -0,0 +1,210 @@
+package widget
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"github.com/francoiscolombo/gomangareader/settings"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// maxUpdateCheckJitter spreads the requests sent to the provider when checking all the titles
+const maxUpdateCheckJitter = 2 * time.Second
+
+// updateCheckerReset wakes up the update checker, so a new interval is taken into account
+var updateCheckerReset = make(chan struct{}, 1)
+
+/*
+startUpdateChecker runs the background checks for new chapters, every UpdateCheckInterval minutes
+with a random jitter of up to 10% of the interval. the first check happens as soon as the previous
+one is older than the interval.
+*/
+func startUpdateChecker() {
+	go func() {
+		for {
+			var wait <-chan time.Time
+			interval := time.Duration(currentConfig().Config.UpdateCheckInterval) * time.Minute
+			if interval > 0 {
+				jitter := time.Duration(rand.Int63n(int64(interval/10) + 1))
+				delay := interval - time.Since(oldestUpdateCheck()) + jitter
+				if delay < time.Minute {
+					delay = time.Minute
+				}
+				wait = time.After(delay)
+			}
+			select {
+			case <-wait:
+				checkForUpdates()
+			case <-updateCheckerReset:
+			}
+		}
+	}()
+}
+
+// resetUpdateChecker restarts the wait of the update checker with the current interval
+func resetUpdateChecker() {
+	select {
+	case updateCheckerReset <- struct{}{}:
+	default:
+	}
+}
+
+// oldestUpdateCheck returns the time of the oldest update check, zero if a title was never checked
+func oldestUpdateCheck() time.Time {
+	var oldest time.Time
+	found := false
+	for _, manga := range currentConfig().History.Titles {
+		if manga.SkipUpdateCheck {
+			continue
+		}
+		if !found || manga.LastUpdateCheck.Before(oldest) {
+			oldest = manga.LastUpdateCheck
+			found = true
+		}
+	}
+	return oldest
+}
+
+/*
+checkForUpdates runs CheckLastChapter for all the subscribed titles, through the download pool so the
+number of requests sent at the same time stays bounded. the results are stored with the history, the
+library badges are refreshed and a notification summarize the titles that have new chapters.
+this is the only place where the availability of new chapters is computed, the widgets only read it.
+*/
+func checkForUpdates() {
+	provider := settings.MangaReader{}
+	var titles []settings.Manga
+	for _, manga := range currentConfig().History.Titles {
+		if !manga.SkipUpdateCheck {
+			titles = append(titles, manga)
+		}
+	}
+	latest := make([]float64, len(titles))
+	results := make([]<-chan error, len(titles))
+	for i, manga := range titles {
+		i, manga := i, manga
+		time.Sleep(time.Duration(rand.Int63n(int64(maxUpdateCheckJitter))))
+		results[i] = getDownloadPool().Submit(hostOf(settings.MangaReaderSiteUrl), func() error {
+			latest[i] = provider.CheckLastChapter(manga)
+			return nil
+		})
+	}
+	for _, result := range results {
+		<-result
+	}
+
+	var summary []string
+	var updated []settings.Manga
+	var checked []settings.Manga
+	now := time.Now()
+	for i, manga := range titles {
+		if latest[i] <= 0 {
+			// the provider did not answer, keep the previous status
+			continue
+		}
+		previous := manga.AvailableChapter
+		manga.AvailableChapter = latest[i]
+		manga.LastUpdateCheck = now
+		checked = append(checked, manga)
+		if manga.NewChaptersAvailable() && latest[i] > previous {
+			summary = append(summary, fmt.Sprintf("%s: up to chapter %.1f", manga.Name, latest[i]))
+			updated = append(updated, manga)
+		}
+	}
+	// the statuses are copied to the history as it is now, the titles may have changed during the check
+	updateConfig(func(cfg settings.Settings) settings.Settings {
+		cfg.History.Titles = append([]settings.Manga{}, cfg.History.Titles...)
+		for _, manga := range checked {
+			for i := range cfg.History.Titles {
+				if cfg.History.Titles[i].Title == manga.Title {
+					cfg.History.Titles[i].AvailableChapter = manga.AvailableChapter
+					cfg.History.Titles[i].LastUpdateCheck = manga.LastUpdateCheck
+				}
+			}
+		}
+		settings.WriteSettings(cfg)
+		return cfg
+	})
+	for _, manga := range checked {
+		setUpdateStatus(manga)
+	}
+	log.Printf("Background update check done for %d titles, %d with new chapters", len(titles), len(summary))
+
+	nbQueued := autoDownloadChapters(updated)
+	if nbQueued > 0 {
+		summary = append(summary, fmt.Sprintf("%d chapters queued for download", nbQueued))
+	}
+
+	if library != nil {
+		library.Refresh()
+	}
+	if series != nil {
+		series.Refresh()
+	}
+	if len(summary) > 0 {
+		application.SendNotification(fyne.NewNotification(
+			fmt.Sprintf("%d titles have new chapters", len(summary)),
+			strings.Join(summary, "\n"),
+		))
+	}
+}
+
+/*
+autoDownloadChapters enqueues the new chapters of the updated mangas that are downloaded automatically,
+up to AutoDownloadLimit chapters for this run. the chapters list is refreshed first, since the new
+chapters are not known yet. it returns the number of chapters queued.
+*/
+func autoDownloadChapters(updated []settings.Manga) int {
+	provider := settings.MangaReader{}
+	cfg := currentConfig()
+	limit := cfg.Config.AutoDownloadLimit
+	nbQueued := 0
+	for _, manga := range updated {
+		if !manga.AutoDownloadEnabled(cfg.Config) {
+			continue
+		}
+		if limit > 0 && nbQueued >= limit {
+			log.Printf("Auto download limit of %d chapters reached, %s will be downloaded on the next run", limit, manga.Name)
+			continue
+		}
+		current, ok := findManga(manga.Title)
+		if !ok {
+			continue
+		}
+		current = provider.BuildChaptersList(&current)
+		// only the chapters list is saved, the manga may have been changed while it was built
+		current, ok = updateManga(manga.Title, func(stored *settings.Manga) {
+			stored.Chapters = current.Chapters
+			if stored.LastChapter <= 1 {
+				stored.LastChapter = current.LastChapter
+			}
+		})
+		if !ok {
+			continue
+		}
+		remaining := 0
+		if limit > 0 {
+			remaining = limit - nbQueued
+		}
+		nbQueued += queueMissingChapters(current, remaining, true)
+	}
+	return nbQueued
+}
+
+// setUpdateStatus copy the update status of a manga to the widgets displaying it
+func setUpdateStatus(manga settings.Manga) {
+	if library != nil {
+		for _, tb := range library.Items {
+			if tb.Title.Title == manga.Title {
+				tb.Title.AvailableChapter = manga.AvailableChapter
+				tb.Title.LastUpdateCheck = manga.LastUpdateCheck
+			}
+		}
+	}
+	if series != nil && series.SelectedManga.Title == manga.Title {
+		series.SelectedManga.AvailableChapter = manga.AvailableChapter
+		series.SelectedManga.LastUpdateCheck = manga.LastUpdateCheck
+	}
+}
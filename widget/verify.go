@@ -0,0 +1,186 @@
+package widget
+
+import (
+	"errors"
+	"fmt"
+	"github.com/francoiscolombo/gomangareader/archive"
+	"github.com/francoiscolombo/gomangareader/settings"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// archiveProblem is a broken chapter archive found by the verification
+type archiveProblem struct {
+	Title   string
+	Name    string
+	Chapter float64
+	Path    string
+	Problem string
+}
+
+// verifyReport is the result of the verification of the library
+type verifyReport struct {
+	Checked  int
+	Problems []archiveProblem
+}
+
+// String returns the report as displayed to the user
+func (report verifyReport) String() string {
+	lines := []string{fmt.Sprintf("%d archives checked, %d broken.", report.Checked, len(report.Problems))}
+	for _, p := range report.Problems {
+		lines = append(lines, fmt.Sprintf("%s chapter %03.1f: %s", p.Name, p.Chapter, p.Problem))
+	}
+	return strings.Join(lines, "\n")
+}
+
+/*
+verifyChapter checks a chapter archive: it must not be empty or truncated, the checksums of all the
+entries must be correct, every page must decode, and the number of pages must match the number
+recorded when the chapter was downloaded. it returns an empty string when the archive is fine.
+*/
+func verifyChapter(manga settings.Manga, chapter float64, cbzArchive string) string {
+	info, err := os.Stat(cbzArchive)
+	if err != nil {
+		return err.Error()
+	}
+	if info.Size() == 0 {
+		return "the archive is empty"
+	}
+	if _, err = archive.Verify(cbzArchive); err != nil {
+		return err.Error()
+	}
+	r, err := archive.Open(cbzArchive)
+	if err != nil {
+		return err.Error()
+	}
+	defer r.Close()
+	pages := r.Pages()
+	if len(pages) == 0 {
+		return "the archive has no pages"
+	}
+	for i, name := range pages {
+		data, err := r.ReadPage(i)
+		if err != nil {
+			return fmt.Sprintf("page %s can not be read: %s", name, err)
+		}
+		format, err := detectImageFormat(data, "")
+		if err == nil {
+			err = validateImage(data, format)
+		}
+		if err != nil {
+			return fmt.Sprintf("page %s is not a valid image: %s", name, err)
+		}
+	}
+	if expected, ok := manga.PageCounts[settings.ChapterKey(chapter)]; ok && expected != len(pages) {
+		return fmt.Sprintf("the archive has %d pages, %d were downloaded", len(pages), expected)
+	}
+	return ""
+}
+
+/*
+verifyLibrary checks all the chapter archives of the library, through the download pool so several
+archives are checked at the same time. the chapters not downloaded are ignored.
+*/
+func verifyLibrary() verifyReport {
+	cfg := currentConfig()
+	type check struct {
+		problem archiveProblem
+		result  <-chan error
+	}
+	var checks []*check
+	for _, manga := range cfg.History.Titles {
+		if !cfg.Config.RootAvailable(manga.Root) {
+			log.Printf("The library root %s of %s is offline, its chapters are not verified", manga.RootName(), manga.Name)
+			continue
+		}
+		for _, chapter := range manga.Chapters {
+			cbzArchive := chapterArchive(manga, chapter)
+			if _, err := os.Stat(cbzArchive); os.IsNotExist(err) {
+				continue
+			}
+			manga, chapter := manga, chapter
+			c := &check{problem: archiveProblem{Title: manga.Title, Name: manga.Name, Chapter: chapter, Path: cbzArchive}}
+			c.result = getDownloadPool().Submit("", func() error {
+				c.problem.Problem = verifyChapter(manga, chapter, cbzArchive)
+				return nil
+			})
+			checks = append(checks, c)
+		}
+	}
+	var report verifyReport
+	for _, c := range checks {
+		<-c.result
+		report.Checked++
+		if c.problem.Problem != "" {
+			report.Problems = append(report.Problems, c.problem)
+		}
+	}
+	log.Printf("Library verified, %d archives checked, %d broken", report.Checked, len(report.Problems))
+	return report
+}
+
+/*
+quarantineArchive moves a broken archive to the .quarantine folder of the library, so it is not read
+anymore but can still be inspected. it returns the new path of the archive.
+*/
+func quarantineArchive(cbzArchive string) (string, error) {
+	quarantine := filepath.Join(currentConfig().Config.LibraryPath, ".quarantine")
+	err := os.MkdirAll(quarantine, os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(quarantine, filepath.Base(cbzArchive))
+	if _, err = os.Stat(target); err == nil {
+		target = filepath.Join(quarantine, fmt.Sprintf("%s-%s", time.Now().Format("20060102150405"), filepath.Base(cbzArchive)))
+	}
+	return target, os.Rename(cbzArchive, target)
+}
+
+/*
+repairLibrary moves the broken archives of a report to the quarantine, and queues their chapters to be
+downloaded again. it returns the number of chapters queued.
+*/
+func repairLibrary(report verifyReport) (int, error) {
+	nbQueued := 0
+	var failures []string
+	for _, p := range report.Problems {
+		if reader != nil && reader.Manga.Title == p.Title && reader.Chapter == p.Chapter {
+			closeReader(p.Title)
+		}
+		target, err := quarantineArchive(p.Path)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", p.Path, err))
+			continue
+		}
+		log.Printf("%s moved to %s: %s", p.Path, target, p.Problem)
+		// the thumbnail came from the broken archive
+		if manga, ok := findManga(p.Title); ok {
+			_ = os.Remove(settings.ThumbnailPath(currentConfig().Config, manga, p.Chapter))
+		}
+		if queue.Enqueue(p.Title, p.Chapter, false) {
+			nbQueued++
+		}
+	}
+	if len(failures) > 0 {
+		return nbQueued, errors.New(fmt.Sprintf("%d archives could not be moved to the quarantine:\n%s", len(failures), strings.Join(failures, "\n")))
+	}
+	return nbQueued, nil
+}
+
+/*
+VerifyLibrary checks all the chapter archives of the library and returns the report. when repair is
+true, the broken archives are moved to the quarantine and downloaded again before returning.
+*/
+func VerifyLibrary(repair bool) (string, error) {
+	loadConfig()
+	report := verifyLibrary()
+	if !repair || len(report.Problems) == 0 {
+		return report.String(), nil
+	}
+	nbQueued, err := repairLibrary(report)
+	queue.Wait()
+	return fmt.Sprintf("%s\n%d chapters downloaded again.", report, nbQueued), err
+}
@@ -0,0 +1,279 @@
+package widget
+
+import (
+	"errors"
+	"github.com/francoiscolombo/gomangareader/settings"
+	"github.com/fsnotify/fsnotify"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// watchQuietPeriod is the time without changes to wait before reloading, so a bulk copy is seen once
+	watchQuietPeriod = 2 * time.Second
+	// watchMaxDelay is the longest time a change can wait, even if the folder keeps changing
+	watchMaxDelay = 30 * time.Second
+)
+
+/*
+libraryWatcher watches the library folder and the settings file. the changes are collected until the
+folder is quiet, then the library is reconciled with the history, or the settings are reloaded.
+*/
+type libraryWatcher struct {
+	watcher         *fsnotify.Watcher
+	lock            sync.Mutex
+	folders         map[string]bool
+	roots           []string
+	timer           *time.Timer
+	firstChange     time.Time
+	libraryChanged  bool
+	settingsChanged bool
+	// suspended is the number of operations moving the archives, the changes wait until they are done
+	suspended int
+}
+
+var watcher *libraryWatcher
+
+// startLibraryWatcher starts watching the library folder and the settings file
+func startLibraryWatcher() {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("Error when trying to watch the library, changes made outside of the application will not be seen: %s", err)
+		return
+	}
+	watcher = &libraryWatcher{watcher: w, folders: map[string]bool{}}
+	// the settings file is often replaced rather than written, so its folder is watched
+	if err = w.Add(filepath.Dir(settings.SettingsPath())); err != nil {
+		log.Printf("Error when trying to watch %s: %s", settings.SettingsPath(), err)
+	}
+	watcher.watchLibrary(currentConfig().Config)
+	go watcher.run()
+}
+
+/*
+watchLibrary watches the available roots of the library and their series folders, the hidden folders
+are ignored. a root back online is watched the next time the library is watched.
+*/
+func (lw *libraryWatcher) watchLibrary(cfg settings.Config) {
+	var roots []string
+	for _, root := range cfg.LibraryRoots() {
+		if cfg.RootAvailable(root.Name) {
+			roots = append(roots, filepath.Clean(root.Path))
+		}
+	}
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	if strings.Join(lw.roots, "\n") != strings.Join(roots, "\n") {
+		for folder := range lw.folders {
+			_ = lw.watcher.Remove(folder)
+		}
+		lw.folders = map[string]bool{}
+		lw.roots = roots
+	}
+	for _, root := range roots {
+		lw.watchFolder(root)
+	}
+}
+
+// rootOf returns the root of the library containing path, or an empty string
+func (lw *libraryWatcher) rootOf(path string) string {
+	for _, root := range lw.roots {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return root
+		}
+	}
+	return ""
+}
+
+// watchFolder watches a folder and all its sub folders, the lock must be held
+func (lw *libraryWatcher) watchFolder(folder string) {
+	_ = filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != lw.rootOf(path) && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if lw.folders[path] {
+			return nil
+		}
+		if err = lw.watcher.Add(path); err != nil {
+			log.Printf("Error when trying to watch %s: %s", path, err)
+			return nil
+		}
+		lw.folders[path] = true
+		return nil
+	})
+}
+
+func (lw *libraryWatcher) run() {
+	for {
+		select {
+		case event, ok := <-lw.watcher.Events:
+			if !ok {
+				return
+			}
+			lw.handle(event)
+		case err, ok := <-lw.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Error while watching the library: %s", err)
+		}
+	}
+}
+
+// handle records a change, the temporary and hidden files of the library are ignored
+func (lw *libraryWatcher) handle(event fsnotify.Event) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	if filepath.Clean(event.Name) == filepath.Clean(settings.SettingsPath()) {
+		lw.settingsChanged = true
+		lw.schedule()
+		return
+	}
+	if lw.rootOf(event.Name) == "" || strings.HasPrefix(filepath.Base(event.Name), ".") {
+		return
+	}
+	if event.Op&fsnotify.Create != 0 {
+		// a new series folder, or a folder copied with its archives
+		lw.watchFolder(event.Name)
+	}
+	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && lw.folders[event.Name] {
+		delete(lw.folders, event.Name)
+		lw.libraryChanged = true
+	}
+	if strings.EqualFold(filepath.Ext(event.Name), ".cbz") {
+		lw.libraryChanged = true
+	}
+	if lw.libraryChanged {
+		lw.schedule()
+	}
+}
+
+// schedule waits for the quiet period before applying the changes, the lock must be held
+func (lw *libraryWatcher) schedule() {
+	if lw.timer == nil {
+		lw.firstChange = time.Now()
+		lw.timer = time.AfterFunc(watchQuietPeriod, lw.flush)
+		return
+	}
+	if time.Since(lw.firstChange) < watchMaxDelay {
+		lw.timer.Reset(watchQuietPeriod)
+	}
+}
+
+/*
+suspendWatcher stops applying the changes of the library folder while the application moves archives
+itself, like when the library is renamed. the returned function resumes the watching.
+*/
+func suspendWatcher() func() {
+	if watcher == nil {
+		return func() {}
+	}
+	watcher.lock.Lock()
+	watcher.suspended++
+	watcher.lock.Unlock()
+	return func() {
+		watcher.lock.Lock()
+		defer watcher.lock.Unlock()
+		watcher.suspended--
+	}
+}
+
+// flush applies the changes collected since the last flush
+func (lw *libraryWatcher) flush() {
+	lw.lock.Lock()
+	if lw.suspended > 0 {
+		lw.timer = time.AfterFunc(watchQuietPeriod, lw.flush)
+		lw.lock.Unlock()
+		return
+	}
+	libraryChanged, settingsChanged := lw.libraryChanged, lw.settingsChanged
+	lw.libraryChanged, lw.settingsChanged = false, false
+	lw.timer = nil
+	lw.lock.Unlock()
+	if settingsChanged && settings.SettingsModified() {
+		reloadSettings()
+		return
+	}
+	if libraryChanged {
+		syncLibraryFolder()
+	}
+}
+
+/*
+reloadSettings reads the settings file changed by another process, and displays the library again. a
+settings file that can not be read, for example because it is still being written, is ignored.
+*/
+func reloadSettings() {
+	cfg, err := settings.LoadSettings()
+	if err == nil && cfg.Config.LibraryPath == "" {
+		err = errors.New("the library folder is missing")
+	}
+	if err != nil {
+		log.Printf("The settings file %s was changed but can not be read, it is ignored: %s", settings.SettingsPath(), err)
+		return
+	}
+	log.Printf("The settings file %s was changed, reloading the library", settings.SettingsPath())
+	setConfig(cfg)
+	applyArchiveLimits()
+	resizeDownloadPool()
+	resetUpdateChecker()
+	watcher.watchLibrary(currentConfig().Config)
+	reloadLibrary(libraryTabs.SelectedIndex())
+}
+
+/*
+syncLibraryFolder reconciles the history with the library folder after archives were added or removed
+outside of the application: the archives recognized are adopted, and the chapters whose archive was
+removed are not downloaded anymore. the widgets of the mangas changed are refreshed.
+*/
+func syncLibraryFolder() {
+	report := scanLibrary()
+	changed := map[string]bool{}
+	for _, f := range report.Missing {
+		if queue.Contains(f.Title, f.Chapter) {
+			// the archive is being downloaded again
+			continue
+		}
+		if forgetChapter(f) {
+			changed[f.Title] = true
+		}
+	}
+	for _, f := range report.Unknown {
+		if f.Title == "" {
+			log.Printf("%s was added to the library, but it was not recognized", f.Path)
+			continue
+		}
+		if err := adoptArchive(f); err != nil {
+			log.Printf("Error when trying to adopt %s: %s", f.Path, err)
+			continue
+		}
+		changed[f.Title] = true
+	}
+	if len(changed) == 0 || library == nil {
+		return
+	}
+	for title := range changed {
+		manga, ok := findManga(title)
+		if !ok {
+			continue
+		}
+		extractFirstPages(manga)
+		// the series and chapters widgets share the manga of the library item
+		for _, tb := range library.Items {
+			if tb.Title.Title == title {
+				*tb.Title = manga
+			}
+		}
+	}
+	library.Refresh()
+	if chapters != nil && changed[chapters.Title] {
+		refreshTabsContent(chapters.Manga, libraryTabs.SelectedIndex())
+	}
+}